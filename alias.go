@@ -0,0 +1,149 @@
+package repoowners
+
+import (
+	"sort"
+	"strings"
+)
+
+// AliasCycleError is returned, wrapped in a *FileError, when aliases in
+// OWNERS_ALIASES refer to each other cyclically. A strict Loader reports
+// the cycle as a Diagnostic of the *ValidationError instead.
+type AliasCycleError struct {
+	// Cycle is the list of alias names which forms the cycle.
+	// It begins and ends with the same alias.
+	Cycle []string
+}
+
+func (e *AliasCycleError) Error() string {
+	return "alias cycle is detected: " + strings.Join(e.Cycle, " -> ")
+}
+
+// AliasNode is a node of the expansion tree of an alias.
+type AliasNode struct {
+	// Name is the alias name or the username.
+	Name string
+	// Members are the members of the alias, ordered by name.
+	// Members is nil if the node is a username.
+	Members []*AliasNode
+}
+
+// IsAlias returns true if the node is an alias.
+func (n *AliasNode) IsAlias() bool {
+	return n.Members != nil
+}
+
+// Usernames returns the set of usernames the node is expanded to.
+func (n *AliasNode) Usernames() UsernameSet {
+	if !n.IsAlias() {
+		return newUsernameSet(n.Name)
+	}
+	ret := UsernameSet{}
+	for _, m := range n.Members {
+		ret = ret.Union(m.Usernames())
+	}
+	return ret
+}
+
+// isAlias returns true if given name is a defined alias.
+func (o *Owners) isAlias(name string) bool {
+	_, ok := o.aliases[normalizeName(name)]
+	return ok
+}
+
+// AliasTree returns the expansion tree of given alias.
+// The second return value is false if the alias is not defined.
+func (o *Owners) AliasTree(alias string) (*AliasNode, bool) {
+	if !o.isAlias(alias) {
+		return nil, false
+	}
+	return o.aliasTree(alias, map[string]bool{}), true
+}
+
+func (o *Owners) aliasTree(name string, visiting map[string]bool) *AliasNode {
+	key := normalizeName(name)
+	members, ok := o.aliases[key]
+	if !ok || visiting[key] {
+		return &AliasNode{Name: name}
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	n := &AliasNode{Name: name, Members: []*AliasNode{}}
+	for _, m := range members.List() {
+		n.Members = append(n.Members, o.aliasTree(m, visiting))
+	}
+	return n
+}
+
+// expandAlias returns the set of usernames given name is expanded to,
+// following nested aliases. Names which are not aliases are returned as is.
+// visiting holds the aliases being expanded to guard against cycles.
+func (o *Owners) expandAlias(name string, visiting map[string]bool) UsernameSet {
+	key := normalizeName(name)
+	members, ok := o.aliases[key]
+	if !ok {
+		return newUsernameSet(name)
+	}
+	if visiting[key] {
+		return UsernameSet{}
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	ret := UsernameSet{}
+	for _, m := range members.List() {
+		ret = ret.Union(o.expandAlias(m, visiting))
+	}
+	return ret
+}
+
+// findAliasCycle returns a cycle in aliases, or nil if there is none.
+func findAliasCycle(aliases map[string]UsernameSet) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+
+	var visit func(key, name string) []string
+	visit = func(key, name string) []string {
+		switch state[key] {
+		case done:
+			return nil
+		case visiting:
+			for i := range stack {
+				if normalizeName(stack[i]) == key {
+					return append(append([]string{}, stack[i:]...), name)
+				}
+			}
+		}
+		state[key] = visiting
+		stack = append(stack, name)
+		for _, m := range aliases[key].List() {
+			mkey := normalizeName(m)
+			if _, ok := aliases[mkey]; !ok {
+				continue
+			}
+			if cycle := visit(mkey, m); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[key] = done
+		return nil
+	}
+
+	keys := make([]string, 0, len(aliases))
+	for key := range aliases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if cycle := visit(key, key); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
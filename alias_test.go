@@ -0,0 +1,138 @@
+package repoowners
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNestedAliases(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"foo": newUsernameSet("admins", "dave"),
+		},
+		aliases: map[string]UsernameSet{
+			"admins":  newUsernameSet("alice", "leads"),
+			"leads":   newUsernameSet("bob", "members"),
+			"members": newUsernameSet("charlie"),
+		},
+	}
+	got := owners.Approvers("foo")
+	want := newUsernameSet("alice", "bob", "charlie", "dave")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+
+	tree, ok := owners.AliasTree("Admins")
+	if !ok {
+		t.Fatal("admins should be defined")
+	}
+	wantTree := &AliasNode{
+		Name: "Admins",
+		Members: []*AliasNode{
+			{Name: "alice"},
+			{
+				Name: "leads",
+				Members: []*AliasNode{
+					{Name: "bob"},
+					{
+						Name:    "members",
+						Members: []*AliasNode{{Name: "charlie"}},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(tree, wantTree) {
+		t.Errorf("unexpected alias tree:\n  got:  %+v\n  want: %+v", tree, wantTree)
+		return
+	}
+	if got, want := tree.Usernames(), newUsernameSet("alice", "bob", "charlie"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected usernames:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+	if _, ok := owners.AliasTree("dave"); ok {
+		t.Error("dave should not be an alias")
+		return
+	}
+}
+
+func TestFindAliasCycle(t *testing.T) {
+	tests := []struct {
+		label   string
+		aliases map[string]UsernameSet
+		want    []string
+	}{
+		{
+			label: "no cycle",
+			aliases: map[string]UsernameSet{
+				"admins":  newUsernameSet("leads", "members"),
+				"leads":   newUsernameSet("members"),
+				"members": newUsernameSet("alice"),
+			},
+			want: nil,
+		},
+		{
+			label: "self",
+			aliases: map[string]UsernameSet{
+				"admins": newUsernameSet("alice", "Admins"),
+			},
+			want: []string{"admins", "Admins"},
+		},
+		{
+			label: "indirect",
+			aliases: map[string]UsernameSet{
+				"admins":  newUsernameSet("leads"),
+				"leads":   newUsernameSet("members"),
+				"members": newUsernameSet("admins"),
+			},
+			want: []string{"admins", "leads", "members", "admins"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got := findAliasCycle(tt.aliases)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected cycle:\n  got:  %+v\n  want: %+v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestLoadAliasCycle(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	if err := fs.WriteFile(filepath.Join(basePath, DefaultAliasesFilename), []byte(`aliases:
+  admins:
+  - leads
+  leads:
+  - admins
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
+	ferr, ok := err.(*FileError)
+	if !ok || ferr.Path != DefaultAliasesFilename {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if _, ok := ferr.Err.(*AliasCycleError); !ok {
+		t.Errorf("unexpected error: %v", ferr.Err)
+		return
+	}
+}
+
+func TestLoadAliasesParseError(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	writeFiles(t, fs, basePath, map[string]string{
+		".github/OWNERS_ALIASES": "aliases: [\n",
+	})
+	_, err := NewLoader(WithFs(fs), WithAliasesPaths(".github/OWNERS_ALIASES")).LoadLocal(basePath)
+	if ferr, ok := err.(*FileError); !ok || ferr.Path != ".github/OWNERS_ALIASES" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
@@ -0,0 +1,214 @@
+package repoowners
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// ApprovalStatus is the approval status of a set of changed files.
+type ApprovalStatus struct {
+	// Approved is true if all the files are approved
+	// and the required reviews are satisfied.
+	Approved bool
+	// Dirs is the approval status of each OWNERS directory
+	// which governs the files, ordered by path.
+	Dirs []DirApproval
+	// RequiredReviews is the status of the required reviews
+	// for the files.
+	RequiredReviews RequiredReviewStatus
+}
+
+// ApprovedDirs returns the OWNERS directories which are approved.
+func (s ApprovalStatus) ApprovedDirs() []string {
+	var ret []string
+	for _, d := range s.Dirs {
+		if d.Approved() {
+			ret = append(ret, d.Dir)
+		}
+	}
+	return ret
+}
+
+// UnapprovedDirs returns the OWNERS directories which still need approval.
+func (s ApprovalStatus) UnapprovedDirs() []string {
+	var ret []string
+	for _, d := range s.Dirs {
+		if !d.Approved() {
+			ret = append(ret, d.Dir)
+		}
+	}
+	return ret
+}
+
+// DirApproval is the approval status of the files
+// which are governed by one OWNERS directory.
+type DirApproval struct {
+	// Dir is the directory of the OWNERS file, which is the nearest
+	// one having approvers from the files. The root directory is "".
+	Dir string
+	// Files are the changed files governed by the OWNERS file.
+	Files []string
+	// UnapprovedFiles are the files in Files which are not approved yet.
+	UnapprovedFiles []string
+	// Approvers is the set of users who can approve any of the files.
+	Approvers UsernameSet
+	// ApprovedBy is the set of users in Approvers who have approved.
+	ApprovedBy UsernameSet
+}
+
+// Approved returns true if all the files are approved.
+func (d DirApproval) Approved() bool {
+	return len(d.UnapprovedFiles) == 0
+}
+
+// Approval returns the approval status of given changed files,
+// given the users who have approved them and the users who have
+// reviewed them.
+// Each file is governed by the nearest OWNERS file which has approvers
+// for it, and is approved if one of its approvers has approved,
+// including the ones inherited from the parent directories.
+// The files are approved as a whole only if the required reviews,
+// which are checked with reviewedBy as RequiredReviews does,
+// are also satisfied.
+func (o *Owners) Approval(files []string, approvedBy []string, reviewedBy []string) ApprovalStatus {
+	approved := newUsernameSet(approvedBy...)
+	dirs := map[repoPath]*DirApproval{}
+	for _, file := range files {
+		p := newRepoPath(file)
+		dir := o.ownersDir(p.parent(), p, o.approvers, o.approverFilters)
+		d, ok := dirs[dir]
+		if !ok {
+			d = &DirApproval{
+				Dir:        string(dir),
+				Approvers:  UsernameSet{},
+				ApprovedBy: UsernameSet{},
+			}
+			dirs[dir] = d
+		}
+		approvers := o.ApproversForFile(file)
+		approvedFile := approvers.Intersection(approved)
+		d.Files = append(d.Files, file)
+		if len(approvedFile) == 0 {
+			d.UnapprovedFiles = append(d.UnapprovedFiles, file)
+		}
+		d.Approvers = d.Approvers.Union(approvers)
+		d.ApprovedBy = d.ApprovedBy.Union(approvedFile)
+	}
+
+	status := ApprovalStatus{
+		RequiredReviews: o.RequiredReviews(files, reviewedBy),
+	}
+	status.Approved = status.RequiredReviews.Satisfied
+	for _, d := range dirs {
+		sort.Strings(d.Files)
+		sort.Strings(d.UnapprovedFiles)
+		status.Dirs = append(status.Dirs, *d)
+		if !d.Approved() {
+			status.Approved = false
+		}
+	}
+	sort.Slice(status.Dirs, func(i, j int) bool {
+		return status.Dirs[i].Dir < status.Dirs[j].Dir
+	})
+	return status
+}
+
+// SuggestApprovers returns a small set of approvers who can approve all
+// of given changed files together.
+// Like Prow's approve plugin, the approvers listed in the nearest OWNERS
+// file of each file are preferred over the ones inherited from the parent
+// directories, and the approver who covers the most files is chosen
+// greedily. Ties are broken randomly with given seed, so the result is
+// deterministic for the same seed. Files which nobody can approve are
+// ignored.
+func (o *Owners) SuggestApprovers(files []string, seed int64) UsernameSet {
+	unapproved := map[string]bool{}
+	candidates := UsernameSet{}
+	for _, file := range files {
+		unapproved[file] = true
+		p := newRepoPath(file)
+		dir := o.ownersDir(p.parent(), p, o.approvers, o.approverFilters)
+		candidates = candidates.Union(o.activeAt(dir, p, o.approvers, o.approverFilters, o.emeritusApprovers))
+	}
+	r := rand.New(rand.NewSource(seed))
+	shuffled := candidates.List()
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	var chosen []string
+	for len(unapproved) > 0 {
+		approver, covered := o.mostCoveringApprover(shuffled, unapproved)
+		if len(covered) == 0 {
+			// nearest approvers cannot cover the rest,
+			// fall back to the inherited ones
+			file := sortedKeys(unapproved)[0]
+			fallback := o.ApproversForFile(file).List()
+			r.Shuffle(len(fallback), func(i, j int) {
+				fallback[i], fallback[j] = fallback[j], fallback[i]
+			})
+			approver, covered = o.mostCoveringApprover(fallback, unapproved)
+			if len(covered) == 0 {
+				// nobody can approve
+				delete(unapproved, file)
+				continue
+			}
+		}
+		chosen = append(chosen, approver)
+		for _, file := range covered {
+			delete(unapproved, file)
+		}
+	}
+	return newUsernameSet(o.pruneApprovers(chosen, files)...)
+}
+
+// mostCoveringApprover returns the approver in candidates who can approve
+// the most files in files, with the files.
+// The earlier candidate wins a tie.
+func (o *Owners) mostCoveringApprover(candidates []string, files map[string]bool) (string, []string) {
+	var best string
+	var bestCovered []string
+	for _, candidate := range candidates {
+		var covered []string
+		for file := range files {
+			if o.ApproversForFile(file).Has(candidate) {
+				covered = append(covered, file)
+			}
+		}
+		if len(covered) > len(bestCovered) {
+			best, bestCovered = candidate, covered
+		}
+	}
+	return best, bestCovered
+}
+
+// pruneApprovers removes redundant approvers from chosen, latest first,
+// as long as the rest still approve the same files.
+func (o *Owners) pruneApprovers(chosen []string, files []string) []string {
+	target := approvedFiles(o.Approval(files, chosen, nil))
+	for i := len(chosen) - 1; i >= 0; i-- {
+		rest := append(append([]string{}, chosen[:i]...), chosen[i+1:]...)
+		if approvedFiles(o.Approval(files, rest, nil)) == target {
+			chosen = rest
+		}
+	}
+	return chosen
+}
+
+// approvedFiles returns the number of approved files in s.
+func approvedFiles(s ApprovalStatus) int {
+	n := 0
+	for _, d := range s.Dirs {
+		n += len(d.Files) - len(d.UnapprovedFiles)
+	}
+	return n
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,237 @@
+package repoowners
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestApproval(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":        newUsernameSet("alice"),
+			"foo":     newUsernameSet("bob"),
+			"foo/bar": newUsernameSet("charlie"),
+			"qux":     newUsernameSet("dave"),
+		},
+		options: map[repoPath]options{
+			"qux": {NoInheritance: true},
+		},
+	}
+	files := []string{
+		"foo/bar/a.go",
+		"foo/bar/baz/b.go",
+		"foo/c.go",
+		"qux/d.go",
+		"README.md",
+	}
+
+	tests := []struct {
+		label          string
+		approvedBy     []string
+		wantApproved   bool
+		wantApprovedBy map[string]UsernameSet
+	}{
+		{
+			label:        "nobody",
+			approvedBy:   nil,
+			wantApproved: false,
+			wantApprovedBy: map[string]UsernameSet{
+				"":        newUsernameSet(),
+				"foo":     newUsernameSet(),
+				"foo/bar": newUsernameSet(),
+				"qux":     newUsernameSet(),
+			},
+		},
+		{
+			label:        "root approver does not cover no_inherit",
+			approvedBy:   []string{"Alice"},
+			wantApproved: false,
+			wantApprovedBy: map[string]UsernameSet{
+				"":        newUsernameSet("alice"),
+				"foo":     newUsernameSet("alice"),
+				"foo/bar": newUsernameSet("alice"),
+				"qux":     newUsernameSet(),
+			},
+		},
+		{
+			label:        "all approved",
+			approvedBy:   []string{"bob", "alice", "dave"},
+			wantApproved: true,
+			wantApprovedBy: map[string]UsernameSet{
+				"":        newUsernameSet("alice"),
+				"foo":     newUsernameSet("alice", "bob"),
+				"foo/bar": newUsernameSet("alice", "bob"),
+				"qux":     newUsernameSet("dave"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got := owners.Approval(files, tt.approvedBy, nil)
+			if got.Approved != tt.wantApproved {
+				t.Errorf("%t != %t", got.Approved, tt.wantApproved)
+				return
+			}
+			gotApprovedBy := map[string]UsernameSet{}
+			for _, d := range got.Dirs {
+				gotApprovedBy[d.Dir] = d.ApprovedBy
+			}
+			if !reflect.DeepEqual(gotApprovedBy, tt.wantApprovedBy) {
+				t.Errorf("unexpected approvedBy:\n  got:  %+v\n  want: %+v", gotApprovedBy, tt.wantApprovedBy)
+				return
+			}
+		})
+	}
+
+	got := owners.Approval(files, []string{"bob"}, nil)
+	wantFiles := map[string][]string{
+		"":        {"README.md"},
+		"foo":     {"foo/c.go"},
+		"foo/bar": {"foo/bar/a.go", "foo/bar/baz/b.go"},
+		"qux":     {"qux/d.go"},
+	}
+	gotFiles := map[string][]string{}
+	for _, d := range got.Dirs {
+		gotFiles[d.Dir] = d.Files
+	}
+	if !reflect.DeepEqual(gotFiles, wantFiles) {
+		t.Errorf("unexpected files:\n  got:  %+v\n  want: %+v", gotFiles, wantFiles)
+		return
+	}
+	if want := []string{"foo", "foo/bar"}; !reflect.DeepEqual(got.ApprovedDirs(), want) {
+		t.Errorf("unexpected approved dirs:\n  got:  %+v\n  want: %+v", got.ApprovedDirs(), want)
+		return
+	}
+	if want := []string{"", "qux"}; !reflect.DeepEqual(got.UnapprovedDirs(), want) {
+		t.Errorf("unexpected unapproved dirs:\n  got:  %+v\n  want: %+v", got.UnapprovedDirs(), want)
+		return
+	}
+}
+
+func TestSuggestApprovers(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":        newUsernameSet("alice"),
+			"foo":     newUsernameSet("bob", "carol"),
+			"foo/bar": newUsernameSet("dave"),
+			"qux":     newUsernameSet("eve"),
+			"empty":   newUsernameSet("nobody"),
+		},
+		options: map[repoPath]options{
+			"empty": {NoInheritance: true},
+		},
+		aliases: map[string]UsernameSet{
+			"nobody": newUsernameSet(),
+		},
+	}
+	files := []string{"foo/bar/a.go", "foo/b.go", "qux/c.go", "empty/d.go"}
+
+	for seed := int64(0); seed < 10; seed++ {
+		got := owners.SuggestApprovers(files, seed)
+		if len(got) != 2 {
+			t.Errorf("unexpected number of approvers: %s", got)
+			return
+		}
+		if !got.Has("eve") || !(got.Has("bob") || got.Has("carol")) {
+			t.Errorf("unexpected approvers: %s", got)
+			return
+		}
+		if again := owners.SuggestApprovers(files, seed); !reflect.DeepEqual(got, again) {
+			t.Errorf("not deterministic: %s != %s", got, again)
+			return
+		}
+	}
+
+	// only the inherited approvers can approve
+	owners2 := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"foo":     newUsernameSet("bob", "carol"),
+			"foo/bar": newUsernameSet("nobody"),
+		},
+		aliases: map[string]UsernameSet{
+			"nobody": newUsernameSet(),
+		},
+	}
+	got := owners2.SuggestApprovers([]string{"foo/bar/a.go"}, 0)
+	if len(got) != 1 || !(got.Has("bob") || got.Has("carol")) {
+		t.Errorf("unexpected approvers: %s", got)
+		return
+	}
+}
+
+func TestApprovalWithFilters(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"": newUsernameSet("alice"),
+		},
+		approverFilters: map[repoPath][]filteredSet{
+			"foo": {
+				{pattern: regexp.MustCompile(`\.go$`), usernames: newUsernameSet("bob")},
+				{pattern: regexp.MustCompile(`\.md$`), usernames: newUsernameSet("charlie")},
+			},
+		},
+	}
+	files := []string{"foo/a.go", "foo/b.md", "foo/c.txt"}
+
+	got := owners.Approval(files, []string{"bob"}, nil)
+	want := []DirApproval{
+		{
+			Dir:             "",
+			Files:           []string{"foo/c.txt"},
+			UnapprovedFiles: []string{"foo/c.txt"},
+			Approvers:       newUsernameSet("alice"),
+			ApprovedBy:      newUsernameSet(),
+		},
+		{
+			Dir:             "foo",
+			Files:           []string{"foo/a.go", "foo/b.md"},
+			UnapprovedFiles: []string{"foo/b.md"},
+			Approvers:       newUsernameSet("alice", "bob", "charlie"),
+			ApprovedBy:      newUsernameSet("bob"),
+		},
+	}
+	if !reflect.DeepEqual(got.Dirs, want) {
+		t.Errorf("unexpected approval:\n  got:  %+v\n  want: %+v", got.Dirs, want)
+		return
+	}
+
+	// alice is the nearest approver of foo/c.txt and covers everything
+	suggested := owners.SuggestApprovers(files, 0)
+	if want := newUsernameSet("alice"); !reflect.DeepEqual(suggested, want) {
+		t.Errorf("unexpected suggestion: %s", suggested)
+		return
+	}
+}
+
+func TestApprovalWithRequiredReviewers(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"": newUsernameSet("alice"),
+		},
+		requiredReviewers: map[repoPath]UsernameSet{
+			"sec": newUsernameSet("bob"),
+		},
+	}
+	files := []string{"sec/a.go", "README.md"}
+
+	got := owners.Approval(files, []string{"alice"}, nil)
+	if got.Approved {
+		t.Errorf("approved without the required review: %+v", got)
+		return
+	}
+	if want := []string{"sec"}; !reflect.DeepEqual(got.RequiredReviews.BlockingDirs(), want) {
+		t.Errorf("unexpected blocking dirs:\n  got:  %+v\n  want: %+v", got.RequiredReviews.BlockingDirs(), want)
+		return
+	}
+	if want := []string{""}; !reflect.DeepEqual(got.ApprovedDirs(), want) {
+		t.Errorf("unexpected approved dirs:\n  got:  %+v\n  want: %+v", got.ApprovedDirs(), want)
+		return
+	}
+
+	got = owners.Approval(files, []string{"alice"}, []string{"Bob"})
+	if !got.Approved {
+		t.Errorf("not approved with the required review: %+v", got)
+		return
+	}
+}
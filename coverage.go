@@ -0,0 +1,82 @@
+package repoowners
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CoverageReport is a report of the directories lacking approvers.
+type CoverageReport struct {
+	// Dirs is the number of the directories checked.
+	Dirs int
+	// NoApprovers are the directories which have no approvers,
+	// e.g. because of no_inherit or a missing root OWNERS file.
+	NoApprovers []string
+	// SingleApprover are the directories which have only one approver.
+	SingleApprover []SingleApproverDir
+}
+
+// SingleApproverDir is a directory which has only one approver.
+type SingleApproverDir struct {
+	Dir      string
+	Approver string
+}
+
+// String renders the report as text, starting with the summary counts.
+func (r CoverageReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d directories: %d without approvers, %d with a single approver\n", r.Dirs, len(r.NoApprovers), len(r.SingleApprover))
+	for _, dir := range r.NoApprovers {
+		fmt.Fprintf(&b, "  %q: no approvers\n", dir)
+	}
+	for _, d := range r.SingleApprover {
+		fmt.Fprintf(&b, "  %q: only %s\n", d.Dir, d.Approver)
+	}
+	return b.String()
+}
+
+// Coverage reports the directories of the repository which have no
+// approvers or only one approver. The approvers of a directory are the
+// ones resolved by ApproversForDir, and the ones given by the filters
+// which match any of the files in the directory.
+// The directories are the ones walked when the Owners was loaded.
+func (o *Owners) Coverage() CoverageReport {
+	dirs := make([]string, len(o.dirs))
+	for i, dir := range o.dirs {
+		dirs[i] = string(dir)
+	}
+	return o.CoverageOf(dirs)
+}
+
+// CoverageOf is like Coverage but checks given directories.
+// The filters are matched against the files found in the walk,
+// so they do not count for the directories which were not walked.
+func (o *Owners) CoverageOf(dirs []string) CoverageReport {
+	r := CoverageReport{Dirs: len(dirs)}
+	for _, dir := range dirs {
+		p := newRepoPath(dir)
+		approvers := o.dirApprovers(p)
+		switch len(approvers) {
+		case 0:
+			r.NoApprovers = append(r.NoApprovers, string(p))
+		case 1:
+			r.SingleApprover = append(r.SingleApprover, SingleApproverDir{
+				Dir:      string(p),
+				Approver: approvers.List()[0],
+			})
+		}
+	}
+	return r
+}
+
+// dirApprovers returns the approvers of dir and the approvers given by
+// the filters matching any of the files in dir.
+func (o *Owners) dirApprovers(dir repoPath) UsernameSet {
+	approvers := o.ApproversForDir(string(dir))
+	for _, name := range o.files[dir] {
+		file := repoPath(path.Join(string(dir), name))
+		approvers = approvers.Union(o.entries(dir, file, o.approvers, o.approverFilters, o.emeritusApprovers, o.options))
+	}
+	return approvers
+}
@@ -0,0 +1,107 @@
+package repoowners
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	gogit "gopkg.in/src-d/go-git.v4"
+)
+
+func TestCoverage(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":          "approvers:\n- alice\n- bob\n",
+		"foo/OWNERS":      "no_inherit: true\napprovers:\n- admins\n",
+		"foo/sub/main.go": "package sub\n",
+		"bar/OWNERS":      "no_inherit: true\nreviewers:\n- alice\n",
+		"bar/x/main.go":   "package x\n",
+		"baz/main.go":     "package baz\n",
+		"qux/OWNERS":      "no_inherit: true\nfilters:\n  \"\\\\.go$\":\n    approvers:\n    - bob\n    - carol\n",
+		"qux/sub/x.go":    "package sub\n",
+		"qux/dir.go/b.md": "# b\n",
+		"OWNERS_ALIASES":  "aliases:\n  admins:\n  - Charlie\n",
+	}
+	writeFiles(t, fs, basePath, files)
+	o, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := o.Coverage()
+	want := CoverageReport{
+		Dirs:        9,
+		NoApprovers: []string{"bar", "bar/x", "qux", "qux/dir.go"},
+		SingleApprover: []SingleApproverDir{
+			{Dir: "foo", Approver: "Charlie"},
+			{Dir: "foo/sub", Approver: "Charlie"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected report:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+	wantText := `9 directories: 4 without approvers, 2 with a single approver
+  "bar": no approvers
+  "bar/x": no approvers
+  "qux": no approvers
+  "qux/dir.go": no approvers
+  "foo": only Charlie
+  "foo/sub": only Charlie
+`
+	if got.String() != wantText {
+		t.Errorf("unexpected text:\n%s", got.String())
+		return
+	}
+
+	// the filters count only for the files in the directory,
+	// not for the name of the directory
+	got = o.CoverageOf([]string{"qux/sub", "qux/dir.go"})
+	want = CoverageReport{Dirs: 2, NoApprovers: []string{"qux/dir.go"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected report:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+
+	got = o.CoverageOf([]string{".", "./bar/"})
+	want = CoverageReport{Dirs: 2, NoApprovers: []string{"bar"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected report:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
+
+func TestCoverageGitCheckout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repoowners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := gogit.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, dir, map[string]string{
+		DefaultOwnersFilename: "approvers:\n- alice\n",
+		"foo/main.go":         "package foo\n",
+	})
+
+	o, err := LoadLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// .git is not walked
+	got := o.Coverage()
+	want := CoverageReport{
+		Dirs: 2,
+		SingleApprover: []SingleApproverDir{
+			{Dir: "", Approver: "alice"},
+			{Dir: "foo", Approver: "alice"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected report:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
@@ -0,0 +1,233 @@
+package repoowners
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Role is a role which OWNERS files give to users.
+// The values are the keys in OWNERS files.
+type Role string
+
+// Roles given by OWNERS files.
+const (
+	RoleApprover         Role = "approvers"
+	RoleReviewer         Role = "reviewers"
+	RoleRequiredReviewer Role = "required_reviewers"
+)
+
+// roles are all the roles, in the order they are reported.
+var roles = []Role{RoleApprover, RoleReviewer, RoleRequiredReviewer}
+
+// roleEntries returns the entries, the filtered entries and the
+// emeritus entries of given role.
+func (o *Owners) roleEntries(role Role) (map[repoPath]UsernameSet, map[repoPath][]filteredSet, map[repoPath]UsernameSet) {
+	switch role {
+	case RoleApprover:
+		return o.approvers, o.approverFilters, o.emeritusApprovers
+	case RoleReviewer:
+		return o.reviewers, o.reviewerFilters, o.emeritusReviewers
+	case RoleRequiredReviewer:
+		return o.requiredReviewers, o.requiredReviewerFilters, nil
+	}
+	return nil, nil, nil
+}
+
+// Explanation explains the roles of a user for a path.
+type Explanation struct {
+	User string
+	Path string
+	// Steps are the OWNERS files applying to the path, nearest first.
+	Steps []ExplainStep
+}
+
+// ExplainStep is one OWNERS file in an Explanation.
+type ExplainStep struct {
+	// Dir is the directory of the OWNERS file. The root directory is "".
+	Dir string
+	// File is the path of the OWNERS file.
+	File string
+	// Grants are the roles the OWNERS file gives to the user.
+	Grants []Grant
+	// NoInherit is true if the OWNERS file has no_inherit option,
+	// which stops the inheritance from the parent directories.
+	NoInherit bool
+}
+
+// Grant is a role given to a user by an OWNERS file.
+type Grant struct {
+	Role Role
+	// Filter is the pattern of the filter listing the user,
+	// or empty if the user is listed at the top level.
+	Filter string
+	// Via is the chain of aliases the user is a member of, outermost
+	// first, or nil if the user is listed by the username.
+	Via []string
+	// Emeritus is true if the user is also listed as emeritus in the
+	// same OWNERS file, which cancels the grant.
+	Emeritus bool
+}
+
+func (g Grant) String() string {
+	s := string(g.Role)
+	if g.Filter != "" {
+		s += fmt.Sprintf(" in filter %q", g.Filter)
+	}
+	if g.Via != nil {
+		s += " via " + strings.Join(g.Via, " -> ")
+	}
+	if g.Emeritus {
+		s += " (emeritus)"
+	}
+	return s
+}
+
+// Roles returns the effective roles of the user, that is the roles
+// granted by any of the steps and not cancelled by emeritus.
+func (e Explanation) Roles() []Role {
+	granted := map[Role]bool{}
+	for _, step := range e.Steps {
+		for _, g := range step.Grants {
+			if !g.Emeritus {
+				granted[g.Role] = true
+			}
+		}
+	}
+	var ret []Role
+	for _, role := range roles {
+		if granted[role] {
+			ret = append(ret, role)
+		}
+	}
+	return ret
+}
+
+// String renders the explanation as text, e.g.:
+//
+//	alice: approvers for "pkg/foo/bar.go"
+//	  pkg/foo/OWNERS: approvers via admins -> leads [no_inherit]
+func (e Explanation) String() string {
+	var b strings.Builder
+	names := make([]string, 0, len(roles))
+	for _, role := range e.Roles() {
+		names = append(names, string(role))
+	}
+	if len(names) == 0 {
+		names = append(names, "no roles")
+	}
+	fmt.Fprintf(&b, "%s: %s for %q\n", e.User, strings.Join(names, ", "), e.Path)
+	for _, step := range e.Steps {
+		grants := make([]string, len(step.Grants))
+		for i, g := range step.Grants {
+			grants[i] = g.String()
+		}
+		if len(grants) == 0 {
+			grants = append(grants, "no roles")
+		}
+		fmt.Fprintf(&b, "  %s: %s", step.File, strings.Join(grants, ", "))
+		if step.NoInherit {
+			b.WriteString(" [no_inherit]")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Explain explains why given user has the roles for given path.
+// It visits the OWNERS files applying to the path in the same way as
+// Approvers, and reports which file gives which role to the user,
+// through which aliases, and where no_inherit stops the inheritance.
+func (o *Owners) Explain(user, path string) Explanation {
+	p := newRepoPath(path)
+	e := Explanation{User: user, Path: string(p)}
+	for _, dir := range o.chain(p, o.options) {
+		if !o.hasOwnersConfig(dir) {
+			continue
+		}
+		step := ExplainStep{
+			Dir:       string(dir),
+			File:      o.ownersFile(dir),
+			NoInherit: o.options[dir].NoInheritance,
+		}
+		rel := p.rel(dir)
+		match := func(re *regexp.Regexp) bool {
+			return re.MatchString(rel)
+		}
+		for _, role := range roles {
+			step.Grants = append(step.Grants, o.grants(user, role, dir, match)...)
+		}
+		e.Steps = append(e.Steps, step)
+	}
+	return e
+}
+
+// hasOwnersConfig returns true if dir has OWNERS configuration.
+func (o *Owners) hasOwnersConfig(dir repoPath) bool {
+	if _, ok := o.ownersFiles[dir]; ok {
+		return true
+	}
+	if _, ok := o.options[dir]; ok {
+		return true
+	}
+	for _, role := range roles {
+		mp, fmp, _ := o.roleEntries(role)
+		if len(mp[dir]) > 0 || len(fmp[dir]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// grants returns the grants of given role to user by the OWNERS file
+// of dir. Only the filters whose patterns match are used.
+func (o *Owners) grants(user string, role Role, dir repoPath, match func(*regexp.Regexp) bool) []Grant {
+	mp, fmp, emeritus := o.roleEntries(role)
+	var ret []Grant
+	add := func(names UsernameSet, filter string) {
+		for _, name := range names.List() {
+			for _, via := range o.aliasRoutes(name, user, map[string]bool{}) {
+				ret = append(ret, Grant{Role: role, Filter: filter, Via: via})
+			}
+		}
+	}
+	add(mp[dir], "")
+	for _, f := range fmp[dir] {
+		if match(f.pattern) {
+			add(f.usernames, f.pattern.String())
+		}
+	}
+	if len(ret) > 0 && o.expandAliases(emeritus[dir]).Has(user) {
+		for i := range ret {
+			ret[i].Emeritus = true
+		}
+	}
+	return ret
+}
+
+// aliasRoutes returns the chains of aliases from name to user.
+// If name is user itself, a nil chain is returned.
+// visiting holds the aliases being expanded to guard against cycles.
+func (o *Owners) aliasRoutes(name, user string, visiting map[string]bool) [][]string {
+	key := normalizeName(name)
+	members, ok := o.aliases[key]
+	if !ok {
+		if key == normalizeName(user) {
+			return [][]string{nil}
+		}
+		return nil
+	}
+	if visiting[key] {
+		return nil
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	var routes [][]string
+	for _, m := range members.List() {
+		for _, route := range o.aliasRoutes(m, user, visiting) {
+			routes = append(routes, append([]string{name}, route...))
+		}
+	}
+	return routes
+}
@@ -0,0 +1,101 @@
+package repoowners
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":        newUsernameSet("Alice"),
+			"foo":     newUsernameSet("admins"),
+			"foo/bar": newUsernameSet("bob"),
+		},
+		reviewers: map[repoPath]UsernameSet{
+			"foo/bar": newUsernameSet("alice"),
+		},
+		reviewerFilters: map[repoPath][]filteredSet{
+			"foo": {{regexp.MustCompile(`\.go$`), newUsernameSet("alice")}},
+		},
+		options: map[repoPath]options{
+			"foo":     {NoInheritance: true},
+			"foo/bar": {},
+		},
+		aliases: map[string]UsernameSet{
+			"admins": newUsernameSet("leads"),
+			"leads":  newUsernameSet("alice", "bob"),
+		},
+		ownersFiles: map[repoPath]string{
+			"foo/bar": "foo/bar/OWNERS.yaml",
+		},
+	}
+
+	got := owners.Explain("alice", "./foo/bar/baz.go")
+	want := Explanation{
+		User: "alice",
+		Path: "foo/bar/baz.go",
+		Steps: []ExplainStep{
+			{
+				Dir:    "foo/bar",
+				File:   "foo/bar/OWNERS.yaml",
+				Grants: []Grant{{Role: RoleReviewer}},
+			},
+			{
+				Dir:  "foo",
+				File: "foo/OWNERS",
+				Grants: []Grant{
+					{Role: RoleApprover, Via: []string{"admins", "leads"}},
+					{Role: RoleReviewer, Filter: `\.go$`},
+				},
+				NoInherit: true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected explanation:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+	if roles := got.Roles(); !reflect.DeepEqual(roles, []Role{RoleApprover, RoleReviewer}) {
+		t.Errorf("unexpected roles: %v", roles)
+		return
+	}
+	wantText := `alice: approvers, reviewers for "foo/bar/baz.go"
+  foo/bar/OWNERS.yaml: reviewers
+  foo/OWNERS: approvers via admins -> leads, reviewers in filter "\\.go$" [no_inherit]
+`
+	if got.String() != wantText {
+		t.Errorf("unexpected text:\n%s", got.String())
+		return
+	}
+
+	// emeritus cancels the grant in the same file
+	owners.emeritusApprovers = map[repoPath]UsernameSet{
+		"foo": newUsernameSet("leads"),
+	}
+	got = owners.Explain("alice", "foo/bar")
+	want.Path = "foo/bar"
+	want.Steps[1].Grants = []Grant{{Role: RoleApprover, Via: []string{"admins", "leads"}, Emeritus: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected explanation:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+	if roles := got.Roles(); !reflect.DeepEqual(roles, []Role{RoleReviewer}) {
+		t.Errorf("unexpected roles: %v", roles)
+		return
+	}
+
+	got = owners.Explain("alice", "README.md")
+	want = Explanation{
+		User: "alice",
+		Path: "README.md",
+		Steps: []ExplainStep{
+			{Dir: "", File: "OWNERS", Grants: []Grant{{Role: RoleApprover}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected explanation:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
@@ -6,4 +6,5 @@ require (
 	github.com/spf13/afero v1.2.2
 	gopkg.in/src-d/go-git.v4 v4.12.0
 	gopkg.in/yaml.v2 v2.2.2
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,56 @@
+package repoowners
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+)
+
+const gitignoreFilename = ".gitignore"
+
+// splitPath splits a repository relative path into its elements.
+// The root is split into no elements.
+func splitPath(path string) []string {
+	path = filepath.ToSlash(path)
+	if path == "." || path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// parseIgnorePatterns parses the lines of a gitignore file in dir.
+func parseIgnorePatterns(data []byte, dir string) []gitignore.Pattern {
+	domain := splitPath(dir)
+	var ps []gitignore.Pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		ps = append(ps, gitignore.ParsePattern(line, domain))
+	}
+	return ps
+}
+
+// excluded tells whether path is excluded by the patterns.
+func excluded(patterns []gitignore.Pattern, path string, isDir bool) bool {
+	return gitignore.NewMatcher(patterns).Match(splitPath(path), isDir)
+}
+
+// readGitignore returns the patterns in the .gitignore file of dir, if any.
+func (ld *loading) readGitignore(dir string) ([]gitignore.Pattern, error) {
+	path := filepath.Join(dir, gitignoreFilename)
+	data, err := ld.readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, ld.handleWalkError(path, err)
+	}
+	return parseIgnorePatterns(data, dir), nil
+}
@@ -0,0 +1,73 @@
+package repoowners
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLoadExclude(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":                    "approvers:\n- alice\n",
+		".gitignore":                "# dependencies\nnode_modules/\n",
+		"vendor/foo/OWNERS":         "approvers:\n- bob\n",
+		"third_party/OWNERS":        "approvers:\n- charlie\n",
+		"pkg/third_party/OWNERS":    "approvers:\n- dave\n",
+		"node_modules/bar/OWNERS":   "approvers:\n- ellen\n",
+		"web/.gitignore":            "gen/\n!gen/keep/\n",
+		"web/OWNERS":                "approvers:\n- frank\n",
+		"web/gen/OWNERS":            "approvers:\n- george\n",
+		"web/gen/keep/OWNERS":       "approvers:\n- harry\n",
+		"web/node_modules/x/OWNERS": "approvers:\n- ivy\n",
+	}
+	writeFiles(t, fs, basePath, files)
+
+	tests := []struct {
+		label  string
+		loader *Loader
+		want   []string
+	}{
+		{
+			label:  "default",
+			loader: NewLoader(WithFs(fs)),
+			want: []string{
+				"", "node_modules/bar", "pkg/third_party", "third_party", "vendor/foo",
+				"web", "web/gen", "web/gen/keep", "web/node_modules/x",
+			},
+		},
+		{
+			label:  "exclude patterns",
+			loader: NewLoader(WithFs(fs), WithExcludePatterns("vendor/", "/third_party/")),
+			want: []string{
+				"", "node_modules/bar", "pkg/third_party",
+				"web", "web/gen", "web/gen/keep", "web/node_modules/x",
+			},
+		},
+		{
+			label:  "gitignore",
+			loader: NewLoader(WithFs(fs), WithGitignore(), WithExcludePatterns("vendor/")),
+			want: []string{
+				"", "pkg/third_party", "third_party", "web",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			o, err := tt.loader.LoadLocal(basePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for dir := range o.approvers {
+				got = append(got, string(dir))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected dirs:\n  got:  %v\n  want: %v", got, tt.want)
+				return
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package git
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -8,30 +12,58 @@ import (
 
 	git "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
+// fetchRefSpecs are the refspecs used to keep the bare cache up to date.
+// Both branches and tags are mirrored so that any of them can be resolved.
+var fetchRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// RefNotFoundError is returned when the given ref cannot be resolved
+// to a commit in the repository.
+type RefNotFoundError struct {
+	Repo string
+	Ref  string
+}
+
+func (e *RefNotFoundError) Error() string {
+	return fmt.Sprintf("ref %q is not found in %s", e.Ref, e.Repo)
+}
+
 type Client struct {
 	cacheDir string
 
-	rlm       sync.Mutex
-	repoLocks map[string]*sync.Mutex
+	rlm sync.Mutex
+	// repoLocks are the locks of the repositories, which are held
+	// by sending to and released by receiving from the channels.
+	repoLocks map[string]chan struct{}
 }
 
+// NewClient returns a new Client which caches repositories
+// under a new temporary directory.
 func NewClient() (*Client, error) {
 	cacheDir, err := ioutil.TempDir("", "git-cache")
 	if err != nil {
 		return nil, err
 	}
-	c := &Client{
+	return NewClientWithCacheDir(cacheDir), nil
+}
+
+// NewClientWithCacheDir returns a new Client which caches
+// repositories under given directory.
+func NewClientWithCacheDir(cacheDir string) *Client {
+	return &Client{
 		cacheDir:  cacheDir,
-		repoLocks: map[string]*sync.Mutex{},
+		repoLocks: map[string]chan struct{}{},
 	}
-	return c, nil
 }
 
 // Clean removes the local repository caches.
-func (c Client) Clean() error {
+func (c *Client) Clean() error {
 	return os.RemoveAll(c.cacheDir)
 }
 
@@ -61,65 +93,183 @@ func (repo *Repository) Log() ([]string, error) {
 	return ret, nil
 }
 
-func (c *Client) Clone(repo string) (*Repository, error) {
-	c.lockRepo(repo)
+// Clone clones the repository and checks out given ref.
+// The ref can be a branch name, a tag name or a full commit SHA.
+// If the ref is empty, the default branch of the repository is used.
+func (c *Client) Clone(repo, ref string) (*Repository, error) {
+	return c.CloneContext(context.Background(), repo, ref)
+}
+
+// CloneContext is like Clone but aborts when ctx is done.
+func (c *Client) CloneContext(ctx context.Context, repo, ref string) (*Repository, error) {
+	if err := c.lockRepo(ctx, repo); err != nil {
+		return nil, err
+	}
 	defer c.unlockRepo(repo)
 
-	cache := filepath.Join(c.cacheDir, repo) + ".git"
+	cache, r, err := c.updateCache(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := resolveRef(r, repo, ref)
+	if err != nil {
+		return nil, err
+	}
 
-	// caching bare repository
+	t, err := ioutil.TempDir("", "git")
+	if err != nil {
+		return nil, err
+	}
+	gr, err := git.PlainCloneContext(ctx, t, false, &git.CloneOptions{
+		URL:        cache,
+		NoCheckout: true,
+	})
+	if err != nil {
+		os.RemoveAll(t)
+		return nil, err
+	}
+	wt, err := gr.Worktree()
+	if err != nil {
+		os.RemoveAll(t)
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		os.RemoveAll(t)
+		return nil, err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		os.RemoveAll(t)
+		return nil, err
+	}
+	return &Repository{
+		Dir:     t,
+		repo:    repo,
+		gitRepo: gr,
+	}, nil
+}
+
+// Tree returns the tree of given ref without checking out any file.
+// The ref is resolved in the same way as Clone.
+func (c *Client) Tree(repo, ref string) (*object.Tree, error) {
+	return c.TreeContext(context.Background(), repo, ref)
+}
+
+// TreeContext is like Tree but aborts when ctx is done.
+func (c *Client) TreeContext(ctx context.Context, repo, ref string) (*object.Tree, error) {
+	if err := c.lockRepo(ctx, repo); err != nil {
+		return nil, err
+	}
+	defer c.unlockRepo(repo)
+
+	_, r, err := c.updateCache(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	return tree(r, repo, ref)
+}
+
+// LocalTree returns the tree of given ref in the repository at dir.
+// The ref is resolved in the same way as Clone.
+func LocalTree(dir, ref string) (*object.Tree, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	return tree(r, dir, ref)
+}
+
+func tree(r *git.Repository, repo, ref string) (*object.Tree, error) {
+	hash, err := resolveRef(r, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// cachePath returns the path of the cache of repo.
+// The name is the hash of the URL, which is a valid path on every OS
+// and does not leak the credentials in the URL.
+func (c *Client) cachePath(repo string) string {
+	sum := sha256.Sum256([]byte(repo))
+	return filepath.Join(c.cacheDir, hex.EncodeToString(sum[:])+".git")
+}
+
+// updateCache clones the bare repository into the cache directory,
+// or fetches the latest refs if it is already cached.
+func (c *Client) updateCache(ctx context.Context, repo string) (string, *git.Repository, error) {
+	cache := c.cachePath(repo)
+
+	var r *git.Repository
 	if _, err := os.Stat(cache); os.IsNotExist(err) {
 		// no cache
-		_, err := git.PlainClone(cache, true, &git.CloneOptions{
+		r, err = git.PlainCloneContext(ctx, cache, true, &git.CloneOptions{
 			URL: repo,
 		})
 		if err != nil {
-			return nil, err
+			// do not leave a partial clone as the cache
+			os.RemoveAll(cache)
+			return "", nil, err
 		}
 	} else if err != nil {
-		return nil, err
+		return "", nil, err
 	} else {
 		// there is cache
-		r, err := git.PlainOpen(cache)
+		r, err = git.PlainOpen(cache)
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
-		refSpecs := []config.RefSpec{"refs/heads/*:refs/heads/*"}
-		if err := r.Fetch(&git.FetchOptions{RefSpecs: refSpecs}); err != nil {
-			if err != git.NoErrAlreadyUpToDate {
-				return nil, err
-			}
+	}
+	if err := r.FetchContext(ctx, &git.FetchOptions{RefSpecs: fetchRefSpecs}); err != nil {
+		if err != git.NoErrAlreadyUpToDate {
+			return "", nil, err
 		}
 	}
+	return cache, r, nil
+}
 
-	t, err := ioutil.TempDir("", "git")
-	if err != nil {
-		return nil, err
+// resolveRef resolves given ref to a commit hash.
+func resolveRef(r *git.Repository, repo, ref string) (plumbing.Hash, error) {
+	if ref == "" {
+		ref = string(plumbing.HEAD)
 	}
-	gr, err := git.PlainClone(t, false, &git.CloneOptions{
-		URL: cache,
-	})
+	hash, err := r.ResolveRevision(plumbing.Revision(ref))
 	if err != nil {
-		return nil, err
+		if err == plumbing.ErrReferenceNotFound {
+			return plumbing.ZeroHash, &RefNotFoundError{Repo: repo, Ref: ref}
+		}
+		return plumbing.ZeroHash, err
 	}
-	return &Repository{
-		Dir:     t,
-		repo:    repo,
-		gitRepo: gr,
-	}, nil
+	return *hash, nil
 }
 
-func (c *Client) lockRepo(repo string) {
+// lockRepo locks given repository,
+// or returns the error of ctx if it is done before locking.
+func (c *Client) lockRepo(ctx context.Context, repo string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	c.rlm.Lock()
-	defer c.rlm.Unlock()
-	if _, ok := c.repoLocks[repo]; !ok {
-		c.repoLocks[repo] = &sync.Mutex{}
+	lock, ok := c.repoLocks[repo]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		c.repoLocks[repo] = lock
+	}
+	c.rlm.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	c.repoLocks[repo].Lock()
 }
 
 func (c *Client) unlockRepo(repo string) {
 	c.rlm.Lock()
 	defer c.rlm.Unlock()
-	c.repoLocks[repo].Unlock()
+	<-c.repoLocks[repo]
 }
@@ -1,14 +1,17 @@
 package git_test
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/nasa9084/go-repoowners/internal/pkg/git"
 	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	object "gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
@@ -71,6 +74,22 @@ func (fr *fakeRemote) commit(domain, org, repo, filename string) error {
 	return nil
 }
 
+// setRef points given reference to the current HEAD commit.
+func (fr *fakeRemote) setRef(domain, org, repo string, name plumbing.ReferenceName) (plumbing.Hash, error) {
+	r, err := gogit.PlainOpen(filepath.Join(fr.dir, domain, org, repo))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := r.Storer.SetReference(plumbing.NewHashReference(name, head.Hash())); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return head.Hash(), nil
+}
+
 func TestClone(t *testing.T) {
 	fake, err := newFakeRemote()
 	if err != nil {
@@ -89,7 +108,7 @@ func TestClone(t *testing.T) {
 	}
 	defer c.Clean()
 
-	repo1, err := c.Clone(filepath.Join(fake.dir, "foo", "bar", "baz"))
+	repo1, err := c.Clone(filepath.Join(fake.dir, "foo", "bar", "baz"), "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -98,7 +117,7 @@ func TestClone(t *testing.T) {
 	if err := fake.commit("foo", "bar", "baz", "second"); err != nil {
 		t.Fatal(err)
 	}
-	repo2, err := c.Clone(filepath.Join(fake.dir, "foo", "bar", "baz"))
+	repo2, err := c.Clone(filepath.Join(fake.dir, "foo", "bar", "baz"), "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -117,3 +136,177 @@ func TestClone(t *testing.T) {
 		return
 	}
 }
+
+func TestCloneRef(t *testing.T) {
+	fake, err := newFakeRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.clean()
+	if err := fake.mkRepo("foo", "bar", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := fake.setRef("foo", "bar", "baz", plumbing.NewTagReferenceName("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.commit("foo", "bar", "baz", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.setRef("foo", "bar", "baz", plumbing.NewBranchReferenceName("feature")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.commit("foo", "bar", "baz", "third"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := git.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Clean()
+
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{ref: "", want: 3},
+		{ref: "master", want: 3},
+		{ref: "feature", want: 2},
+		{ref: "v1", want: 1},
+		{ref: first.String(), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			repo, err := c.Clone(filepath.Join(fake.dir, "foo", "bar", "baz"), tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer repo.Clean()
+			logs, err := repo.Log()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != tt.want {
+				t.Errorf("unexpected number of logs: %d != %d", len(logs), tt.want)
+				return
+			}
+		})
+	}
+
+	_, err = c.Clone(filepath.Join(fake.dir, "foo", "bar", "baz"), "no-such-branch")
+	if _, ok := err.(*git.RefNotFoundError); !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
+
+func TestTree(t *testing.T) {
+	fake, err := newFakeRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.clean()
+	if err := fake.mkRepo("foo", "bar", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.setRef("foo", "bar", "baz", plumbing.NewTagReferenceName("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.commit("foo", "bar", "baz", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := git.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Clean()
+
+	tree, err := c.Tree(filepath.Join(fake.dir, "foo", "bar", "baz"), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.File("first"); err != nil {
+		t.Errorf("first should be in the tree: %v", err)
+		return
+	}
+	if _, err := tree.File("second"); err != object.ErrFileNotFound {
+		t.Errorf("second should not be in the tree: %v", err)
+		return
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	fake, err := newFakeRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.clean()
+	if err := fake.mkRepo("foo", "bar", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	cacheDir, err := ioutil.TempDir("", "git-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := git.NewClientWithCacheDir(cacheDir)
+	defer c.Clean()
+
+	if _, err := c.Tree(filepath.Join(fake.dir, "foo", "bar", "baz"), "master"); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the repository is cached in one directory named without the URL
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	if len(infos) != 1 || !infos[0].IsDir() || strings.Contains(infos[0].Name(), "baz") {
+		t.Errorf("unexpected cache entries: %v", names)
+		return
+	}
+}
+
+func TestContext(t *testing.T) {
+	fake, err := newFakeRemote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.clean()
+	if err := fake.mkRepo("foo", "bar", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	repo := filepath.Join(fake.dir, "foo", "bar", "baz")
+
+	c, err := git.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Clean()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.TreeContext(ctx, repo, ""); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if _, err := c.CloneContext(ctx, repo, ""); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	// the client is still usable after cancellation
+	r, err := c.CloneContext(context.Background(), repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Clean()
+	if _, err := c.TreeContext(context.Background(), repo, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
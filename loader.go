@@ -0,0 +1,580 @@
+package repoowners
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/nasa9084/go-repoowners/internal/pkg/git"
+	"github.com/spf13/afero"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+)
+
+// defaultLoader is used by the package level Load functions.
+var defaultLoader = NewLoader()
+
+// Logger is the interface used by Loader to report what it is doing.
+// It must be safe for concurrent use. *log.Logger satisfies this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+// Loader loads OWNERS configuration of repositories.
+// A Loader is safe for concurrent use, and multiple Loaders
+// with different settings can be used at the same time.
+type Loader struct {
+	fs              *afero.Afero
+	ownersFilenames []string
+	aliasesPaths    []string
+	selectOwners    OwnersFileSelector
+	strict          bool
+	tolerant        bool
+	walkErrorPolicy WalkErrorPolicy
+	excludePatterns []gitignore.Pattern
+	useGitignore    bool
+	concurrency     int
+	logger          Logger
+
+	gitCacheDir string
+	gcMu        sync.Mutex
+	gc          *git.Client
+}
+
+// LoaderOption configures a Loader.
+type LoaderOption func(*Loader)
+
+// WithFs sets the filesystem LoadLocal reads from.
+// The default is the OS filesystem.
+func WithFs(fs afero.Fs) LoaderOption {
+	return func(l *Loader) {
+		l.fs = &afero.Afero{Fs: fs}
+	}
+}
+
+// WithGitCacheDir sets the directory remote repositories are cached in.
+// The default is a new temporary directory created on first use.
+func WithGitCacheDir(dir string) LoaderOption {
+	return func(l *Loader) {
+		l.gitCacheDir = dir
+	}
+}
+
+// WithOwnersFilename sets the name of OWNERS files.
+// The default is DefaultOwnersFilename.
+func WithOwnersFilename(name string) LoaderOption {
+	return WithOwnersFilenames(name)
+}
+
+// WithOwnersFilenames sets the accepted names of OWNERS files,
+// e.g. "OWNERS" and "OWNERS.yaml". The order is the priority used
+// when more than one of them exist in the same directory.
+func WithOwnersFilenames(names ...string) LoaderOption {
+	return func(l *Loader) {
+		l.ownersFilenames = names
+	}
+}
+
+// WithAliasesFilename sets the name of the OWNERS_ALIASES file at the
+// repository root. The default is DefaultAliasesFilename.
+func WithAliasesFilename(name string) LoaderOption {
+	return WithAliasesPaths(name)
+}
+
+// WithAliasesPaths sets the search path of the OWNERS_ALIASES file.
+// The paths are relative to the repository root, e.g.
+// ".github/OWNERS_ALIASES", and the first existing one is used.
+func WithAliasesPaths(paths ...string) LoaderOption {
+	return func(l *Loader) {
+		l.aliasesPaths = paths
+	}
+}
+
+// OwnersFileSelector picks the OWNERS file to load when more than one of
+// the accepted filenames exist in the directory dir. The candidates are
+// paths relative to the repository root, ordered by the priority given
+// to WithOwnersFilenames. The returned path must be one of the candidates.
+// Returning an error, or a path which is not a candidate, fails the
+// loading, or skips the directory in tolerant mode.
+// The directories are loaded concurrently, so the selector may be called
+// from multiple goroutines at once and must be safe for concurrent use.
+type OwnersFileSelector func(dir string, candidates []string) (string, error)
+
+// WithOwnersFileSelector sets the OwnersFileSelector.
+// By default the candidate with the highest priority is used.
+func WithOwnersFileSelector(selector OwnersFileSelector) LoaderOption {
+	return func(l *Loader) {
+		l.selectOwners = selector
+	}
+}
+
+func selectFirstOwnersFile(_ string, candidates []string) (string, error) {
+	return candidates[0], nil
+}
+
+// WithStrict enables the strict mode. In the strict mode, unknown keys,
+// duplicate users, empty lists and non-string entries in OWNERS and
+// OWNERS_ALIASES files are rejected. The problems in all the files are
+// returned together as a *ValidationError.
+func WithStrict() LoaderOption {
+	return func(l *Loader) {
+		l.strict = true
+	}
+}
+
+// WithTolerant enables the tolerant mode. In the tolerant mode, the files
+// which fail to load are skipped instead of failing the whole load, and
+// their directories fall back to the owners inherited from the parent
+// directories. The Owners is returned with a *LoadError describing the
+// skipped files. Combined with WithStrict, the files with problems are
+// skipped and their problems are reported as *ValidationError in the
+// *LoadError.
+func WithTolerant() LoaderOption {
+	return func(l *Loader) {
+		l.tolerant = true
+	}
+}
+
+// WithExcludePatterns sets gitignore style patterns of the files and
+// directories to be excluded from the walk, e.g. "vendor/" or
+// "/third_party/". The patterns are relative to the repository root.
+func WithExcludePatterns(patterns ...string) LoaderOption {
+	return func(l *Loader) {
+		l.excludePatterns = make([]gitignore.Pattern, len(patterns))
+		for i, p := range patterns {
+			l.excludePatterns[i] = gitignore.ParsePattern(p, nil)
+		}
+	}
+}
+
+// WithGitignore makes the walk respect the .gitignore files
+// in the repository, in addition to the exclude patterns.
+func WithGitignore() LoaderOption {
+	return func(l *Loader) {
+		l.useGitignore = true
+	}
+}
+
+// WalkErrorPolicy decides how a Loader handles the errors in walking
+// the file tree of a repository, such as unreadable directories.
+// An error in reading the root directory, e.g. a missing base path,
+// always fails the load with a *WalkError.
+type WalkErrorPolicy int
+
+const (
+	// WalkErrorWarn logs the errors and records them in the loaded
+	// Owners, which are available from Owners.WalkErrors.
+	// This is the default.
+	WalkErrorWarn WalkErrorPolicy = iota
+	// WalkErrorFail fails the load with a *WalkError.
+	WalkErrorFail
+	// WalkErrorIgnore ignores the errors.
+	WalkErrorIgnore
+)
+
+// WithWalkErrorPolicy sets how the errors in walking the file tree are handled.
+func WithWalkErrorPolicy(policy WalkErrorPolicy) LoaderOption {
+	return func(l *Loader) {
+		l.walkErrorPolicy = policy
+	}
+}
+
+// WithConcurrency sets the number of the workers which walk the
+// repository and parse the OWNERS files. The default is GOMAXPROCS.
+// The result of loading does not depend on the concurrency.
+func WithConcurrency(n int) LoaderOption {
+	return func(l *Loader) {
+		if n < 1 {
+			n = 1
+		}
+		l.concurrency = n
+	}
+}
+
+// WithLogger sets the logger. By default nothing is logged.
+func WithLogger(logger Logger) LoaderOption {
+	return func(l *Loader) {
+		l.logger = logger
+	}
+}
+
+// NewLoader returns a new Loader configured with given options.
+func NewLoader(opts ...LoaderOption) *Loader {
+	l := &Loader{
+		fs:              &afero.Afero{Fs: afero.NewOsFs()},
+		ownersFilenames: []string{DefaultOwnersFilename},
+		aliasesPaths:    []string{DefaultAliasesFilename},
+		selectOwners:    selectFirstOwnersFile,
+		concurrency:     runtime.GOMAXPROCS(0),
+		logger:          nopLogger{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// gitClient returns the git client of the loader,
+// creating it at the first call.
+func (l *Loader) gitClient() (*git.Client, error) {
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
+	if l.gc != nil {
+		return l.gc, nil
+	}
+	if l.gitCacheDir != "" {
+		l.gc = git.NewClientWithCacheDir(l.gitCacheDir)
+		return l.gc, nil
+	}
+	c, err := git.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	l.gc = c
+	return c, nil
+}
+
+// Clean removes the cache of remote repositories.
+func (l *Loader) Clean() error {
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
+	if l.gc == nil {
+		return nil
+	}
+	if err := l.gc.Clean(); err != nil {
+		return err
+	}
+	l.gc = nil
+	return nil
+}
+
+// RefNotFoundError is returned by LoadRemote when the given ref
+// does not exist in the repository.
+type RefNotFoundError struct {
+	Repository string
+	Ref        string
+}
+
+func (e *RefNotFoundError) Error() string {
+	return fmt.Sprintf("ref %q is not found in %s", e.Ref, e.Repository)
+}
+
+// FileError is an error in loading a file.
+type FileError struct {
+	// Path is the path of the file relative to the repository root.
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// WalkError is an error in walking the file tree of a repository.
+// The subtree under Path is not loaded.
+type WalkError struct {
+	// Path is the path of the file or directory relative to the repository root.
+	Path string
+	Err  error
+}
+
+func (e *WalkError) Error() string {
+	return "walk " + e.Path + ": " + e.Err.Error()
+}
+
+// LoadError is returned by a tolerant Loader together with the loaded
+// Owners when some files are skipped.
+type LoadError struct {
+	// Files are the errors of the skipped files.
+	Files []*FileError
+}
+
+func (e *LoadError) Error() string {
+	msgs := make([]string, len(e.Files))
+	for i, f := range e.Files {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("%d files are skipped:\n%s", len(e.Files), strings.Join(msgs, "\n"))
+}
+
+// LoadRemote loads OWNERS configuration from a remote repository
+// using the default Loader.
+func LoadRemote(domain, org, repo, ref string) (*Owners, error) {
+	return defaultLoader.LoadRemote(domain, org, repo, ref)
+}
+
+// LoadRemoteContext is like LoadRemote but aborts when ctx is done.
+func LoadRemoteContext(ctx context.Context, domain, org, repo, ref string) (*Owners, error) {
+	return defaultLoader.LoadRemoteContext(ctx, domain, org, repo, ref)
+}
+
+// LoadCommit loads OWNERS configuration from given ref of a local git
+// repository using the default Loader.
+func LoadCommit(repoPath, ref string) (*Owners, error) {
+	return defaultLoader.LoadCommit(repoPath, ref)
+}
+
+// LoadCommitContext is like LoadCommit but stops loading when ctx is done.
+func LoadCommitContext(ctx context.Context, repoPath, ref string) (*Owners, error) {
+	return defaultLoader.LoadCommitContext(ctx, repoPath, ref)
+}
+
+// LoadLocal loads OWNERS configuration from a repository on the
+// filesystem using the default Loader.
+func LoadLocal(basePath string) (*Owners, error) {
+	return defaultLoader.LoadLocal(basePath)
+}
+
+// LoadLocalContext is like LoadLocal but stops loading when ctx is done.
+func LoadLocalContext(ctx context.Context, basePath string) (*Owners, error) {
+	return defaultLoader.LoadLocalContext(ctx, basePath)
+}
+
+// LoadRemote loads OWNERS configuration from a remote repository.
+// The ref can be a branch name, a tag name or a full commit SHA,
+// and the default branch is used if it is empty.
+// Only OWNERS and OWNERS_ALIASES files are read from the commit,
+// the working tree is never checked out.
+func (l *Loader) LoadRemote(domain, org, repo, ref string) (*Owners, error) {
+	return l.LoadRemoteContext(context.Background(), domain, org, repo, ref)
+}
+
+// LoadRemoteContext is like LoadRemote but aborts when ctx is done,
+// including the git network operations.
+func (l *Loader) LoadRemoteContext(ctx context.Context, domain, org, repo, ref string) (*Owners, error) {
+	gc, err := l.gitClient()
+	if err != nil {
+		return nil, err
+	}
+	url := remoteURL(domain, org, repo)
+	t, err := gc.TreeContext(ctx, url, ref)
+	if err != nil {
+		return nil, convertGitError(url, err)
+	}
+	return l.load(ctx, &treeSource{tree: t})
+}
+
+// LoadCommit loads OWNERS configuration from given ref of the git
+// repository at repoPath, without reading its working tree.
+// The ref is resolved in the same way as LoadRemote.
+func (l *Loader) LoadCommit(repoPath, ref string) (*Owners, error) {
+	return l.LoadCommitContext(context.Background(), repoPath, ref)
+}
+
+// LoadCommitContext is like LoadCommit but stops loading when ctx is done.
+func (l *Loader) LoadCommitContext(ctx context.Context, repoPath, ref string) (*Owners, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	t, err := git.LocalTree(repoPath, ref)
+	if err != nil {
+		return nil, convertGitError(repoPath, err)
+	}
+	return l.load(ctx, &treeSource{tree: t})
+}
+
+// LoadLocal loads OWNERS configuration from a repository on the filesystem.
+func (l *Loader) LoadLocal(basePath string) (*Owners, error) {
+	return l.LoadLocalContext(context.Background(), basePath)
+}
+
+// LoadLocalContext is like LoadLocal but stops loading when ctx is done.
+func (l *Loader) LoadLocalContext(ctx context.Context, basePath string) (*Owners, error) {
+	return l.load(ctx, fsSource{fs: l.fs, base: basePath})
+}
+
+func convertGitError(repo string, err error) error {
+	if e, ok := err.(*git.RefNotFoundError); ok {
+		return &RefNotFoundError{Repository: repo, Ref: e.Ref}
+	}
+	return err
+}
+
+// remoteURL builds the URL of the repository to clone.
+// The domain may have its own scheme (e.g. "ssh://git@example.com")
+// or be a local path, otherwise https is used.
+func remoteURL(domain, org, repo string) string {
+	if !strings.Contains(domain, "://") && !filepath.IsAbs(domain) {
+		domain = "https://" + domain
+	}
+	return strings.TrimSuffix(domain, "/") + "/" + org + "/" + repo
+}
+
+// loading is the state of one load by a Loader.
+type loading struct {
+	*Loader
+	src source
+	o   *Owners
+	// diags are the problems found in strict mode.
+	diags []Diagnostic
+	// fileErrors are the errors of the files skipped in tolerant mode.
+	fileErrors []*FileError
+
+	// mu guards o.walkErrors, o.dirs and o.files during the walk.
+	mu sync.Mutex
+}
+
+func (l *Loader) load(ctx context.Context, src source) (*Owners, error) {
+	ld := &loading{
+		Loader: l,
+		src:    src,
+		o:      newOwners(),
+	}
+
+	if err := ld.loadAliases(); err != nil {
+		return nil, err
+	}
+
+	results, err := ld.walk(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// apply in the order of directories for the deterministic result
+	for _, res := range results {
+		if err := ld.applyOwners(res); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ld.diags) > 0 {
+		return nil, &ValidationError{Diagnostics: ld.diags}
+	}
+	if len(ld.fileErrors) > 0 {
+		return ld.o, &LoadError{Files: ld.fileErrors}
+	}
+	return ld.o, nil
+}
+
+// readFile reads the whole file at given path from the source.
+func (ld *loading) readFile(path string) ([]byte, error) {
+	f, err := ld.src.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// validate returns the problems of a file as a *ValidationError
+// in strict mode.
+func (ld *loading) validate(path string, data []byte, validateFunc func(string, []byte) []Diagnostic) error {
+	if !ld.strict {
+		return nil
+	}
+	if diags := validateFunc(path, data); len(diags) > 0 {
+		return &ValidationError{Diagnostics: diags}
+	}
+	return nil
+}
+
+// handleFileError handles an error in loading the file at path.
+// In tolerant mode the error is recorded and the file is skipped,
+// and in strict mode the problems are collected to be reported
+// together. Otherwise the error is returned as is.
+func (ld *loading) handleFileError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ld.tolerant {
+		ld.logger.Printf("skipping %s: %v", path, err)
+		ld.fileErrors = append(ld.fileErrors, &FileError{Path: path, Err: err})
+		return nil
+	}
+	if verr, ok := err.(*ValidationError); ok {
+		ld.diags = append(ld.diags, verr.Diagnostics...)
+		return nil
+	}
+	return err
+}
+
+func (ld *loading) loadAliases() error {
+	for _, path := range ld.aliasesPaths {
+		data, err := ld.readFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = ld.loadAliasesFile(path, data)
+		}
+		if err := ld.handleFileError(path, err); err != nil {
+			return &FileError{Path: path, Err: err}
+		}
+		return nil
+	}
+	return nil
+}
+
+func (ld *loading) loadAliasesFile(path string, data []byte) error {
+	if err := ld.validate(path, data, validateAliases); err != nil {
+		return err
+	}
+	ac, err := parseAliases(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	aliases := map[string]UsernameSet{}
+	for alias, list := range ac.Aliases {
+		key := normalizeName(alias)
+		aliases[key] = aliases[key].Union(newUsernameSet(list...))
+	}
+	if cycle := findAliasCycle(aliases); cycle != nil {
+		err := &AliasCycleError{Cycle: cycle}
+		if ld.strict {
+			// reported together with the problems of the OWNERS files
+			return &ValidationError{Diagnostics: []Diagnostic{{Path: path, Message: err.Error()}}}
+		}
+		return err
+	}
+	ld.o.aliases = aliases
+	ld.o.aliasesFile = path
+	ld.logger.Printf("loaded %s", path)
+	return nil
+}
+
+// applyOwners applies the parsed OWNERS file of a directory.
+func (ld *loading) applyOwners(res ownersResult) error {
+	dir := newRepoPath(res.dir)
+	err := res.err
+	if err == nil {
+		err = ld.o.applyOwnersConfig(dir, res.oc)
+	}
+	if err != nil {
+		return ld.handleOwnersError(dir, res.path, err)
+	}
+	ld.o.ownersFiles[dir] = res.path
+	ld.logger.Printf("loaded %s", res.path)
+	return nil
+}
+
+// handleOwnersError handles an error in loading the OWNERS file of dir,
+// marking dir as falling back to the inherited owners if the file is
+// skipped.
+func (ld *loading) handleOwnersError(dir repoPath, path string, err error) error {
+	if err != nil && ld.tolerant {
+		ld.o.fallbacks[dir] = path
+	}
+	if err := ld.handleFileError(path, err); err != nil {
+		return &FileError{Path: path, Err: err}
+	}
+	return nil
+}
+
+// ownersPriority returns the priority of given OWNERS filename,
+// or -1 if it is not an accepted filename. Smaller is higher.
+func (l *Loader) ownersPriority(name string) int {
+	for i, fn := range l.ownersFilenames {
+		if fn == name {
+			return i
+		}
+	}
+	return -1
+}
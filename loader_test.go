@@ -0,0 +1,370 @@
+package repoowners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLoaderOptions(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":          "approvers:\n- alice\n",
+		"OWNERS_ALIASES":  "aliases:\n  admins:\n  - alice\n",
+		"foo/OWNERS":      "approvers:\n- bob\n",
+		"foo/CODEOWNERS":  "approvers:\n- charlie\n",
+		"CODEOWNERS":      "approvers:\n- admins\n",
+		"GROUPS":          "aliases:\n  admins:\n  - dave\n",
+		"bar/baz/OWNERS":  "approvers:\n- ellen\n",
+		"bar/CODEOWNERS":  "approvers:\n- frank\n",
+		"bar/README.md":   "# bar\n",
+		"qux/CODEOWNERS2": "approvers:\n- george\n",
+	}
+	writeFiles(t, fs, basePath, files)
+
+	tests := []struct {
+		label         string
+		loader        *Loader
+		wantApprovers map[repoPath]UsernameSet
+		wantAliases   map[string]UsernameSet
+	}{
+		{
+			label:  "default",
+			loader: NewLoader(WithFs(fs)),
+			wantApprovers: map[repoPath]UsernameSet{
+				"":        newUsernameSet("alice"),
+				"foo":     newUsernameSet("bob"),
+				"bar/baz": newUsernameSet("ellen"),
+			},
+			wantAliases: map[string]UsernameSet{
+				"admins": newUsernameSet("alice"),
+			},
+		},
+		{
+			label:  "custom filenames",
+			loader: NewLoader(WithFs(fs), WithOwnersFilename("CODEOWNERS"), WithAliasesFilename("GROUPS")),
+			wantApprovers: map[repoPath]UsernameSet{
+				"":    newUsernameSet("admins"),
+				"foo": newUsernameSet("charlie"),
+				"bar": newUsernameSet("frank"),
+			},
+			wantAliases: map[string]UsernameSet{
+				"admins": newUsernameSet("dave"),
+			},
+		},
+	}
+
+	// loaders with different settings can be used at the same time
+	var wg sync.WaitGroup
+	results := make([]*Owners, len(tests))
+	errs := make([]error, len(tests))
+	for i, tt := range tests {
+		wg.Add(1)
+		go func(i int, l *Loader) {
+			defer wg.Done()
+			results[i], errs[i] = l.LoadLocal(basePath)
+		}(i, tt.loader)
+	}
+	wg.Wait()
+
+	for i, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			if errs[i] != nil {
+				t.Fatal(errs[i])
+			}
+			o := results[i]
+			if !reflect.DeepEqual(o.approvers, tt.wantApprovers) {
+				t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", o.approvers, tt.wantApprovers)
+				return
+			}
+			if !reflect.DeepEqual(o.aliases, tt.wantAliases) {
+				t.Errorf("unexpected aliases:\n  got:  %+v\n  want: %+v", o.aliases, tt.wantAliases)
+				return
+			}
+		})
+	}
+}
+
+func TestLoaderFilenames(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		".github/OWNERS_ALIASES": "aliases:\n  admins:\n  - alice\n",
+		"OWNERS":                 "approvers:\n- admins\n",
+		"foo/OWNERS.yaml":        "approvers:\n- bob\n",
+		"bar/OWNERS":             "approvers:\n- charlie\n",
+		"bar/OWNERS.yaml":        "approvers:\n- dave\n",
+	}
+	writeFiles(t, fs, basePath, files)
+
+	l := NewLoader(
+		WithFs(fs),
+		WithOwnersFilenames("OWNERS.yaml", "OWNERS"),
+		WithAliasesPaths("OWNERS_ALIASES", ".github/OWNERS_ALIASES"),
+	)
+	o, err := l.LoadLocal(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantApprovers := map[repoPath]UsernameSet{
+		"":    newUsernameSet("admins"),
+		"foo": newUsernameSet("bob"),
+		"bar": newUsernameSet("dave"),
+	}
+	if !reflect.DeepEqual(o.approvers, wantApprovers) {
+		t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", o.approvers, wantApprovers)
+		return
+	}
+	wantAliases := map[string]UsernameSet{
+		"admins": newUsernameSet("alice"),
+	}
+	if !reflect.DeepEqual(o.aliases, wantAliases) {
+		t.Errorf("unexpected aliases:\n  got:  %+v\n  want: %+v", o.aliases, wantAliases)
+		return
+	}
+
+	var mu sync.Mutex
+	var gotCandidates []string
+	l = NewLoader(
+		WithFs(fs),
+		WithOwnersFilenames("OWNERS.yaml", "OWNERS"),
+		WithOwnersFileSelector(func(dir string, candidates []string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			gotCandidates = append(gotCandidates, candidates...)
+			return candidates[len(candidates)-1], nil
+		}),
+	)
+	o, err = l.LoadLocal(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCandidates := []string{"bar/OWNERS.yaml", "bar/OWNERS"}
+	if !reflect.DeepEqual(gotCandidates, wantCandidates) {
+		t.Errorf("unexpected candidates:\n  got:  %+v\n  want: %+v", gotCandidates, wantCandidates)
+		return
+	}
+	if got, want := o.approvers["bar"], newUsernameSet("charlie"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+
+	// the selected path must be one of the candidates
+	outside := WithOwnersFileSelector(func(dir string, candidates []string) (string, error) {
+		return "../../x", nil
+	})
+	_, err = NewLoader(WithFs(fs), WithOwnersFilenames("OWNERS.yaml", "OWNERS"), outside).LoadLocal(basePath)
+	if ferr, ok := err.(*FileError); !ok || ferr.Path != "bar/OWNERS.yaml" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	o, err = NewLoader(WithFs(fs), WithOwnersFilenames("OWNERS.yaml", "OWNERS"), outside, WithTolerant()).LoadLocal(basePath)
+	if _, ok := err.(*LoadError); !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if got, want := o.FallbackDirs(), []string{"bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected fallback dirs:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
+
+func TestLoadTolerant(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":         "approvers:\n- alice\n",
+		"foo/OWNERS":     "approvers: [bob\n",
+		"foo/bar/OWNERS": "approvers:\n- charlie\n",
+		"baz/OWNERS":     "approvers:\n- dave\nfilters:\n  '(':\n    approvers:\n    - ellen\n",
+	}
+	writeFiles(t, fs, basePath, files)
+
+	if _, err := NewLoader(WithFs(fs)).LoadLocal(basePath); err == nil {
+		t.Error("non-tolerant loader should fail")
+		return
+	}
+
+	o, err := NewLoader(WithFs(fs), WithTolerant()).LoadLocal(basePath)
+	lerr, ok := err.(*LoadError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var paths []string
+	for _, ferr := range lerr.Files {
+		paths = append(paths, ferr.Path)
+	}
+	if want := []string{"baz/OWNERS", "foo/OWNERS"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("unexpected skipped files: %v != %v", paths, want)
+		return
+	}
+	if got, want := o.FallbackDirs(), []string{"baz", "foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected fallback dirs: %v != %v", got, want)
+		return
+	}
+	want := map[repoPath]UsernameSet{
+		"":        newUsernameSet("alice"),
+		"foo/bar": newUsernameSet("charlie"),
+	}
+	if !reflect.DeepEqual(o.approvers, want) {
+		t.Errorf("unexpected approvers: %v != %v", o.approvers, want)
+		return
+	}
+
+	// strict problems are reported per skipped file
+	fs.WriteFile(filepath.Join(basePath, "foo/OWNERS"), []byte("aprovers:\n- bob\n"), 0644)
+	_, err = NewLoader(WithFs(fs), WithTolerant(), WithStrict()).LoadLocal(basePath)
+	lerr, ok = err.(*LoadError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lerr.Files) != 2 {
+		t.Fatalf("unexpected skipped files: %v", lerr)
+	}
+	if _, ok := lerr.Files[1].Err.(*ValidationError); !ok {
+		t.Errorf("unexpected error for foo/OWNERS: %v", lerr.Files[1].Err)
+		return
+	}
+}
+
+// brokenSource is a source which fails to read some directories.
+type brokenSource struct {
+	source
+	broken map[string]error
+}
+
+func (s brokenSource) ReadDir(dir string) ([]os.FileInfo, error) {
+	if err, ok := s.broken[dir]; ok {
+		return nil, err
+	}
+	return s.source.ReadDir(dir)
+}
+
+func TestWalkErrorPolicy(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":     "approvers:\n- alice\n",
+		"foo/OWNERS": "approvers:\n- bob\n",
+		"bar/OWNERS": "approvers:\n- charlie\n",
+	}
+	writeFiles(t, fs, basePath, files)
+	errPermission := errors.New("permission denied")
+	src := brokenSource{
+		source: fsSource{fs: fs, base: basePath},
+		broken: map[string]error{"foo": errPermission},
+	}
+	wantApprovers := map[repoPath]UsernameSet{
+		"":    newUsernameSet("alice"),
+		"bar": newUsernameSet("charlie"),
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		o, err := NewLoader().load(context.Background(), src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []*WalkError{{Path: "foo", Err: errPermission}}
+		if !reflect.DeepEqual(o.WalkErrors(), want) {
+			t.Errorf("unexpected walk errors: %v != %v", o.WalkErrors(), want)
+			return
+		}
+		if !reflect.DeepEqual(o.approvers, wantApprovers) {
+			t.Errorf("unexpected approvers: %v != %v", o.approvers, wantApprovers)
+			return
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		_, err := NewLoader(WithWalkErrorPolicy(WalkErrorFail)).load(context.Background(), src)
+		werr, ok := err.(*WalkError)
+		if !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if werr.Path != "foo" || werr.Err != errPermission {
+			t.Errorf("unexpected walk error: %v", werr)
+			return
+		}
+	})
+	t.Run("ignore", func(t *testing.T) {
+		o, err := NewLoader(WithWalkErrorPolicy(WalkErrorIgnore)).load(context.Background(), src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(o.WalkErrors()) != 0 {
+			t.Errorf("walk errors should be ignored: %v", o.WalkErrors())
+			return
+		}
+		if !reflect.DeepEqual(o.approvers, wantApprovers) {
+			t.Errorf("unexpected approvers: %v != %v", o.approvers, wantApprovers)
+			return
+		}
+	})
+}
+
+func TestLoadMissingBase(t *testing.T) {
+	policies := map[string]WalkErrorPolicy{
+		"warn":   WalkErrorWarn,
+		"fail":   WalkErrorFail,
+		"ignore": WalkErrorIgnore,
+	}
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewLoader(WithFs(newMemFS()), WithWalkErrorPolicy(policy)).LoadLocal("no/such/repo")
+			werr, ok := err.(*WalkError)
+			if !ok {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if werr.Path != "." {
+				t.Errorf("unexpected walk error: %v", werr)
+				return
+			}
+		})
+	}
+
+	if _, err := LoadLocal(filepath.Join(os.TempDir(), "repoowners-no-such-repo")); err == nil {
+		t.Error("error should be returned")
+		return
+	}
+}
+
+func TestLoadConcurrency(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(fmt.Sprintf("dir%d", i%7), fmt.Sprintf("sub%d", i))
+		content := fmt.Sprintf("approvers:\n- user%d\nlabels:\n- label%d\n", i, i%3)
+		if i%5 == 0 {
+			// broken files are reported in the same order
+			content = "approvers: [\n"
+		}
+		if err := fs.WriteFile(filepath.Join(basePath, dir, "OWNERS"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, wantErr := NewLoader(WithFs(fs), WithTolerant(), WithConcurrency(1)).LoadLocal(basePath)
+	for _, n := range []int{2, 8, 32} {
+		got, err := NewLoader(WithFs(fs), WithTolerant(), WithConcurrency(n)).LoadLocal(basePath)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrency %d: unexpected owners", n)
+			return
+		}
+		if !reflect.DeepEqual(err, wantErr) {
+			t.Errorf("concurrency %d: unexpected error:\n  got:  %v\n  want: %v", n, err, wantErr)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewLoader(WithFs(fs)).LoadLocalContext(ctx, basePath); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
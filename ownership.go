@@ -0,0 +1,78 @@
+package repoowners
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Ownership is an entry of a user in an OWNERS file.
+type Ownership struct {
+	// Dir is the directory of the OWNERS file. The root directory is "".
+	Dir string
+	// File is the path of the OWNERS file.
+	File  string
+	Grant Grant
+}
+
+// Direct returns true if the user is listed by the username,
+// not through aliases.
+func (o Ownership) Direct() bool {
+	return o.Grant.Via == nil
+}
+
+func (o Ownership) String() string {
+	return o.File + ": " + o.Grant.String()
+}
+
+// Ownerships returns every entry of given user as approvers, reviewers
+// or required reviewers in the OWNERS files, ordered by directory.
+// The entries through aliases have the chain of the aliases in Via,
+// and the entries in filters have the pattern in Filter. The entries
+// cancelled by emeritus in the same file are included with Emeritus.
+func (o *Owners) Ownerships(user string) []Ownership {
+	all := func(*regexp.Regexp) bool {
+		return true
+	}
+	var ret []Ownership
+	for _, dir := range o.configDirs() {
+		for _, role := range roles {
+			for _, g := range o.grants(user, role, dir, all) {
+				ret = append(ret, Ownership{
+					Dir:   string(dir),
+					File:  o.ownersFile(dir),
+					Grant: g,
+				})
+			}
+		}
+	}
+	return ret
+}
+
+// configDirs returns the sorted list of the directories which have
+// OWNERS configuration.
+func (o *Owners) configDirs() []repoPath {
+	seen := map[repoPath]bool{}
+	for dir := range o.ownersFiles {
+		seen[dir] = true
+	}
+	for dir := range o.options {
+		seen[dir] = true
+	}
+	for _, role := range roles {
+		mp, fmp, _ := o.roleEntries(role)
+		for dir := range mp {
+			seen[dir] = true
+		}
+		for dir := range fmp {
+			seen[dir] = true
+		}
+	}
+	dirs := make([]repoPath, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i] < dirs[j]
+	})
+	return dirs
+}
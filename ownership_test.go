@@ -0,0 +1,59 @@
+package repoowners
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestOwnerships(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":        newUsernameSet("Alice"),
+			"foo":     newUsernameSet("admins"),
+			"foo/bar": newUsernameSet("bob"),
+		},
+		reviewers: map[repoPath]UsernameSet{
+			"foo/bar": newUsernameSet("leads"),
+		},
+		requiredReviewerFilters: map[repoPath][]filteredSet{
+			"docs": {{regexp.MustCompile(`\.md$`), newUsernameSet("alice")}},
+		},
+		emeritusApprovers: map[repoPath]UsernameSet{
+			"foo": newUsernameSet("alice"),
+		},
+		aliases: map[string]UsernameSet{
+			"admins": newUsernameSet("leads", "charlie"),
+			"leads":  newUsernameSet("alice"),
+		},
+	}
+
+	got := owners.Ownerships("ALICE")
+	want := []Ownership{
+		{Dir: "", File: "OWNERS", Grant: Grant{Role: RoleApprover}},
+		{Dir: "docs", File: "docs/OWNERS", Grant: Grant{Role: RoleRequiredReviewer, Filter: `\.md$`}},
+		{Dir: "foo", File: "foo/OWNERS", Grant: Grant{Role: RoleApprover, Via: []string{"admins", "leads"}, Emeritus: true}},
+		{Dir: "foo/bar", File: "foo/bar/OWNERS", Grant: Grant{Role: RoleReviewer, Via: []string{"leads"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected ownerships:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+	if s := got[2].String(); s != "foo/OWNERS: approvers via admins -> leads (emeritus)" {
+		t.Errorf("unexpected text: %s", s)
+		return
+	}
+	var direct []bool
+	for _, ow := range got {
+		direct = append(direct, ow.Direct())
+	}
+	if !reflect.DeepEqual(direct, []bool{true, true, false, false}) {
+		t.Errorf("unexpected direct flags: %v", direct)
+		return
+	}
+
+	if got := owners.Ownerships("dave"); len(got) != 0 {
+		t.Errorf("dave should own nothing: %+v", got)
+		return
+	}
+}
@@ -0,0 +1,74 @@
+package repoowners
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// repoPath is a canonical path in a repository relative to its root.
+// The elements are separated by "/", and there are no "." or ".."
+// elements and no leading or trailing slashes, except outsidePath.
+// The root is "".
+// All the paths in Owners are stored and looked up as repoPath.
+type repoPath string
+
+const rootPath repoPath = ""
+
+// outsidePath stands for all the paths out of the repository.
+// No OWNERS configuration applies to it.
+const outsidePath repoPath = ".."
+
+// newRepoPath returns the canonical form of a path given by the caller,
+// e.g. "./foo/", "foo//bar" or "foo\bar" on Windows. The paths out of
+// the repository, such as "../foo", are turned into outsidePath.
+func newRepoPath(p string) repoPath {
+	p = path.Clean(filepath.ToSlash(p))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return outsidePath
+	}
+	if p == "." {
+		return rootPath
+	}
+	return repoPath(strings.TrimPrefix(p, "/"))
+}
+
+func (p repoPath) isRoot() bool {
+	return p == rootPath
+}
+
+func (p repoPath) isOutside() bool {
+	return p == outsidePath
+}
+
+// parent returns the parent directory of p.
+// The parent of the root is the root itself, and the parent of
+// outsidePath is outsidePath itself.
+func (p repoPath) parent() repoPath {
+	if p.isOutside() {
+		return outsidePath
+	}
+	i := strings.LastIndex(string(p), "/")
+	if i < 0 {
+		return rootPath
+	}
+	return p[:i]
+}
+
+// rel returns p relative to dir,
+// which must be one of the ancestors of p or p itself.
+func (p repoPath) rel(dir repoPath) string {
+	if dir.isRoot() {
+		return string(p)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(string(p), string(dir)), "/")
+}
+
+// depth returns the number of elements of p.
+// The depth of the root is 0.
+func (p repoPath) depth() int {
+	if p.isRoot() {
+		return 0
+	}
+	return strings.Count(string(p), "/") + 1
+}
@@ -0,0 +1,87 @@
+package repoowners
+
+import "testing"
+
+func TestNewRepoPath(t *testing.T) {
+	tests := map[string]repoPath{
+		"":              "",
+		".":             "",
+		"/":             "",
+		"./":            "",
+		"foo":           "foo",
+		"./foo/":        "foo",
+		"/foo":          "foo",
+		"foo//bar":      "foo/bar",
+		"foo/./bar/":    "foo/bar",
+		"foo/../bar":    "bar",
+		"/../foo":       "foo",
+		"..":            outsidePath,
+		"../foo":        outsidePath,
+		"../../etc":     outsidePath,
+		"foo/../../bar": outsidePath,
+	}
+	for path, want := range tests {
+		if got := newRepoPath(path); got != want {
+			t.Errorf("newRepoPath(%q): %q != %q", path, got, want)
+		}
+	}
+}
+
+func TestRepoPath(t *testing.T) {
+	tests := []struct {
+		path      repoPath
+		parent    repoPath
+		depth     int
+		relToRoot string
+	}{
+		{path: "", parent: "", depth: 0, relToRoot: ""},
+		{path: "foo", parent: "", depth: 1, relToRoot: "foo"},
+		{path: "foo/bar", parent: "foo", depth: 2, relToRoot: "foo/bar"},
+	}
+	for _, tt := range tests {
+		if got := tt.path.parent(); got != tt.parent {
+			t.Errorf("%q.parent(): %q != %q", tt.path, got, tt.parent)
+		}
+		if got := tt.path.depth(); got != tt.depth {
+			t.Errorf("%q.depth(): %d != %d", tt.path, got, tt.depth)
+		}
+		if got := tt.path.rel(rootPath); got != tt.relToRoot {
+			t.Errorf("%q.rel(root): %q != %q", tt.path, got, tt.relToRoot)
+		}
+	}
+	if got := repoPath("foo/bar/baz.go").rel("foo"); got != "bar/baz.go" {
+		t.Errorf("unexpected relative path: %q", got)
+	}
+	if got := outsidePath.parent(); got != outsidePath {
+		t.Errorf("unexpected parent of outside: %q", got)
+	}
+}
+
+func TestOutsidePath(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":       newUsernameSet("alice"),
+			"secret": newUsernameSet("bob"),
+		},
+		labels: map[repoPath][]string{
+			"": {"root"},
+		},
+	}
+	for _, path := range []string{"..", "../secret", "foo/../../secret", "../../etc/passwd"} {
+		if got := owners.Approvers(path); len(got) != 0 {
+			t.Errorf("unexpected approvers of %q: %s", path, got)
+		}
+		if got := owners.ApproversForFile(path); len(got) != 0 {
+			t.Errorf("unexpected approvers of file %q: %s", path, got)
+		}
+		if got := owners.Labels(path); len(got) != 0 {
+			t.Errorf("unexpected labels of %q: %v", path, got)
+		}
+		if got := owners.Explain("alice", path); len(got.Steps) != 0 {
+			t.Errorf("unexpected explanation of %q: %+v", path, got)
+		}
+	}
+	if status := owners.Approval([]string{"../secret/a.go"}, []string{"alice", "bob"}, nil); status.Approved {
+		t.Errorf("unexpected approval status: %+v", status)
+	}
+}
@@ -3,13 +3,11 @@ package repoowners
 import (
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
-	"strings"
+	"regexp"
+	"sort"
 	"sync"
 
-	"github.com/nasa9084/go-repoowners/internal/pkg/git"
-	"github.com/spf13/afero"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -18,129 +16,143 @@ const (
 	DefaultAliasesFilename = "OWNERS_ALIASES"
 )
 
-var fs = &afero.Afero{Fs: afero.NewOsFs()}
-var gc *git.Client
-
-func init() {
-	c, err := git.NewClient()
-	if err != nil {
-		panic(err)
-	}
-	gc = c
-}
-
 // Owners holds Owners configuration for one repository.
 type Owners struct {
 	// these are path: UsernameSet mapping
-	approvers         map[string]UsernameSet
-	reviewers         map[string]UsernameSet
-	requiredReviewers map[string]UsernameSet
+	approvers         map[repoPath]UsernameSet
+	reviewers         map[repoPath]UsernameSet
+	requiredReviewers map[repoPath]UsernameSet
+	emeritusApprovers map[repoPath]UsernameSet
+	emeritusReviewers map[repoPath]UsernameSet
+
+	// these are path: filtered UsernameSets mapping
+	approverFilters         map[repoPath][]filteredSet
+	reviewerFilters         map[repoPath][]filteredSet
+	requiredReviewerFilters map[repoPath][]filteredSet
+
+	// path: labels mapping
+	labels       map[repoPath][]string
+	labelFilters map[repoPath][]filteredLabels
 
 	// path: options mapping
-	options map[string]options
+	options map[repoPath]options
 
 	// aliasname: []username mapping
 	aliases map[string]UsernameSet
 
+	// path: OWNERS file path mapping
+	ownersFiles map[repoPath]string
+	// path: OWNERS file path mapping of skipped files
+	fallbacks map[repoPath]string
+	// errors in walking the file tree
+	walkErrors []*WalkError
+	// dirs are the directories walked in loading, sorted
+	dirs []repoPath
+	// files are the names of the files in each walked directory
+	files map[repoPath][]string
+	// aliasesFile is the path of OWNERS_ALIASES file.
+	aliasesFile string
+
 	memoizedApprovers         memo
 	memoizedReviewers         memo
 	memoizedRequiredReviewers memo
-
-	// base is a base path of repository.
-	base string
 }
 
-func newOwners() Owners {
-	return Owners{
-		approvers:         map[string]UsernameSet{},
-		reviewers:         map[string]UsernameSet{},
-		requiredReviewers: map[string]UsernameSet{},
-		options:           map[string]options{},
-		aliases:           map[string]UsernameSet{},
+func newOwners() *Owners {
+	return &Owners{
+		approvers:         map[repoPath]UsernameSet{},
+		reviewers:         map[repoPath]UsernameSet{},
+		requiredReviewers: map[repoPath]UsernameSet{},
+		emeritusApprovers: map[repoPath]UsernameSet{},
+		emeritusReviewers: map[repoPath]UsernameSet{},
+
+		approverFilters:         map[repoPath][]filteredSet{},
+		reviewerFilters:         map[repoPath][]filteredSet{},
+		requiredReviewerFilters: map[repoPath][]filteredSet{},
+
+		labels:       map[repoPath][]string{},
+		labelFilters: map[repoPath][]filteredLabels{},
+
+		options:     map[repoPath]options{},
+		aliases:     map[string]UsernameSet{},
+		ownersFiles: map[repoPath]string{},
+		fallbacks:   map[repoPath]string{},
+		files:       map[repoPath][]string{},
 	}
 }
 
-func LoadRemote(domain, org, repo, branch string) (Owners, error) {
-	r, err := gc.Clone(fmt.Sprintf("%s/%s/%s:%s", domain, org, repo, branch))
-	if err != nil {
-		return Owners{}, err
+// ownersFile returns the path of the OWNERS file of given directory.
+func (o *Owners) ownersFile(dir repoPath) string {
+	if f, ok := o.ownersFiles[dir]; ok {
+		return f
 	}
-	return LoadLocal(r.Dir)
+	return filepath.Join(string(dir), DefaultOwnersFilename)
 }
 
-func LoadLocal(basePath string) (Owners, error) {
-	o := newOwners()
-	o.base = basePath
+// filteredSet is a set of usernames which applies only to
+// the files matching the pattern.
+type filteredSet struct {
+	pattern   *regexp.Regexp
+	usernames UsernameSet
+}
 
-	if _, err := fs.Stat(filepath.Join(basePath, DefaultAliasesFilename)); err == nil {
-		f, err := fs.Open(filepath.Join(basePath, DefaultAliasesFilename))
-		if err != nil {
-			return Owners{}, err
-		}
-		defer f.Close()
+// filteredLabels is a list of labels which applies only to
+// the files matching the pattern.
+type filteredLabels struct {
+	pattern *regexp.Regexp
+	labels  []string
+}
 
-		ac, err := parseAliases(f)
-		if err != nil {
-			return Owners{}, err
-		}
-		for alias, list := range ac.Aliases {
-			o.aliases[alias] = newUsernameSet(list...)
-		}
-	}
-	if err := fs.Walk(o.base, o.walkFunc); err != nil {
-		return Owners{}, err
-	}
+// pathKind is how a path is looked up.
+type pathKind int
 
-	return o, nil
+const (
+	// anyPath is looked up as a directory and matched against the filters.
+	anyPath pathKind = iota
+	// filePath is looked up from its containing directory
+	// and matched against the filters.
+	filePath
+	// dirPath is looked up as a directory and never matched
+	// against the filters, which are for files.
+	dirPath
+)
+
+type memoKey struct {
+	path repoPath
+	kind pathKind
 }
 
 type memo struct {
 	sync.Map
 }
 
-func (m *memo) store(path string, set UsernameSet) {
-	m.Map.Store(path, set)
+func (m *memo) store(key memoKey, set UsernameSet) {
+	m.Map.Store(key, set)
 }
 
-func (m memo) load(path string) UsernameSet {
-	val, ok := m.Map.Load(path)
+func (m *memo) load(key memoKey) UsernameSet {
+	val, ok := m.Map.Load(key)
 	if ok {
 		return val.(UsernameSet)
 	}
 	return nil
 }
 
-func (o *Owners) walkFunc(path string, info os.FileInfo, err error) error {
-	if err != nil {
-		return nil
-	}
-	fn := filepath.Base(path)
-	relPath, err := filepath.Rel(o.base, path)
-	if err != nil {
-		return err
-	}
-	relPathDir := filepath.Dir(relPath)
-	if info.Mode().IsDir() || !info.Mode().IsRegular() {
-		return nil
+func (o *Owners) applyOwnersConfig(path repoPath, oc ownersConfig) error {
+	patterns := make([]string, 0, len(oc.Filters))
+	for pattern := range oc.Filters {
+		patterns = append(patterns, pattern)
 	}
-	if fn != DefaultOwnersFilename {
-		return nil
-	}
-
-	f, err := fs.Open(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	oc, err := parseOwners(f)
-	if err != nil {
-		return err
+	sort.Strings(patterns)
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid filter pattern %q: %v", pattern, err)
+		}
+		res[i] = re
 	}
-	o.applyOwnersConfig(relPathDir, oc)
-	return nil
-}
 
-func (o *Owners) applyOwnersConfig(path string, oc ownersConfig) {
 	if len(oc.Approvers) > 0 {
 		o.approvers[path] = newUsernameSet(oc.Approvers...)
 	}
@@ -150,83 +162,215 @@ func (o *Owners) applyOwnersConfig(path string, oc ownersConfig) {
 	if len(oc.RequiredReviewers) > 0 {
 		o.requiredReviewers[path] = newUsernameSet(oc.RequiredReviewers...)
 	}
+	if len(oc.EmeritusApprovers) > 0 {
+		o.emeritusApprovers[path] = newUsernameSet(oc.EmeritusApprovers...)
+	}
+	if len(oc.EmeritusReviewers) > 0 {
+		o.emeritusReviewers[path] = newUsernameSet(oc.EmeritusReviewers...)
+	}
+	if len(oc.Labels) > 0 {
+		o.labels[path] = oc.Labels
+	}
+	for i, pattern := range patterns {
+		re := res[i]
+		fc := oc.Filters[pattern]
+		if len(fc.Approvers) > 0 {
+			o.approverFilters[path] = append(o.approverFilters[path], filteredSet{re, newUsernameSet(fc.Approvers...)})
+		}
+		if len(fc.Reviewers) > 0 {
+			o.reviewerFilters[path] = append(o.reviewerFilters[path], filteredSet{re, newUsernameSet(fc.Reviewers...)})
+		}
+		if len(fc.RequiredReviewers) > 0 {
+			o.requiredReviewerFilters[path] = append(o.requiredReviewerFilters[path], filteredSet{re, newUsernameSet(fc.RequiredReviewers...)})
+		}
+		if len(fc.Labels) > 0 {
+			o.labelFilters[path] = append(o.labelFilters[path], filteredLabels{re, fc.Labels})
+		}
+	}
 	o.options[path] = oc.Options
+	return nil
 }
 
-func (o Owners) entries(path string, mp map[string]UsernameSet, opts map[string]options) UsernameSet {
+// lookup returns the usernames in mp and fmp for given path looked up
+// as kind, memoizing the result in m.
+func (o *Owners) lookup(m *memo, path repoPath, kind pathKind, mp map[repoPath]UsernameSet, fmp map[repoPath][]filteredSet, emeritus map[repoPath]UsernameSet) UsernameSet {
+	key := memoKey{path: path, kind: kind}
+	if set := m.load(key); set != nil {
+		return set
+	}
+	start := path
+	switch kind {
+	case filePath:
+		start = path.parent()
+	case dirPath:
+		fmp = nil
+	}
+	set := o.entries(start, path, mp, fmp, emeritus, o.options)
+	m.store(key, set)
+	return set
+}
+
+// entries returns the usernames in mp and fmp applying to path
+// in the chain of start, with aliases expanded.
+func (o *Owners) entries(start, path repoPath, mp map[repoPath]UsernameSet, fmp map[repoPath][]filteredSet, emeritus map[repoPath]UsernameSet, opts map[repoPath]options) UsernameSet {
 	ret := UsernameSet{}
-	for {
-		us, ok := mp[path]
-		if ok {
-			ret = ret.Union(us)
-		}
-		if opts[path].NoInheritance {
-			break
+	for _, dir := range o.chain(start, opts) {
+		ret = ret.Union(o.activeAt(dir, path, mp, fmp, emeritus))
+	}
+	return ret
+}
+
+// activeAt returns the usernames in the OWNERS file of dir which apply
+// to given path with aliases expanded, excluding the emeritus users
+// listed in the same file.
+func (o *Owners) activeAt(dir, path repoPath, mp map[repoPath]UsernameSet, fmp map[repoPath][]filteredSet, emeritus map[repoPath]UsernameSet) UsernameSet {
+	ret := o.expandAliases(entriesAt(dir, path, mp, fmp))
+	if len(emeritus[dir]) > 0 {
+		ret.Delete(o.expandAliases(emeritus[dir]).List()...)
+	}
+	return ret
+}
+
+// entriesAt returns the usernames in the OWNERS file of dir which apply
+// to given path, that is the ones in mp and the ones in fmp whose pattern
+// matches the path relative to dir.
+func entriesAt(dir, path repoPath, mp map[repoPath]UsernameSet, fmp map[repoPath][]filteredSet) UsernameSet {
+	ret := mp[dir].Copy()
+	if filters := fmp[dir]; len(filters) > 0 {
+		rel := path.rel(dir)
+		for _, f := range filters {
+			if f.pattern.MatchString(rel) {
+				ret = ret.Union(f.usernames)
+			}
 		}
-		if path == "" {
+	}
+	return ret
+}
+
+// chain returns the paths whose OWNERS configuration applies to given
+// path, from the path itself up to the root, nearest first.
+// It stops at the first path which has no_inherit option.
+// The chain of outsidePath is empty.
+func (o *Owners) chain(path repoPath, opts map[repoPath]options) []repoPath {
+	if path.isOutside() {
+		return nil
+	}
+	var ret []repoPath
+	for {
+		ret = append(ret, path)
+		if opts[path].NoInheritance || path.isRoot() {
 			break
 		}
-		path = filepath.Dir(path)
-		if path == "." {
-			path = ""
-		}
-		path = strings.TrimSuffix(path, "/")
+		path = path.parent()
 	}
-	ret = o.expandAliases(ret)
 	return ret
 }
 
+// ownersDir returns the nearest path in the chain of start
+// which has an entry in mp or fmp applying to path.
+// If there is no such path, the last path of the chain is returned,
+// or start itself if the chain is empty.
+func (o *Owners) ownersDir(start, path repoPath, mp map[repoPath]UsernameSet, fmp map[repoPath][]filteredSet) repoPath {
+	chain := o.chain(start, o.options)
+	if len(chain) == 0 {
+		return start
+	}
+	for _, dir := range chain {
+		if len(entriesAt(dir, path, mp, fmp)) > 0 {
+			return dir
+		}
+	}
+	return chain[len(chain)-1]
+}
+
 func (o *Owners) expandAliases(usernames UsernameSet) UsernameSet {
-	usernames = usernames.Copy()
+	ret := UsernameSet{}
 	for _, username := range usernames.List() {
-		if expanded, ok := o.aliases[username]; ok {
-			usernames.Delete(username)
-			usernames = usernames.Union(expanded)
-		}
+		ret = ret.Union(o.expandAlias(username, map[string]bool{}))
 	}
-	return usernames
+	return ret
 }
 
-// Approvers returns a set of approvers for given file path.
-func (o *Owners) Approvers(path string) UsernameSet {
-	if approvers := o.memoizedApprovers.load(path); approvers != nil {
-		return approvers
+// FallbackDirs returns a sorted list of the directories whose OWNERS
+// files are skipped by a tolerant Loader. The owners inherited from the
+// parent directories are used for them. The root directory is "".
+func (o *Owners) FallbackDirs() []string {
+	dirs := make([]string, 0, len(o.fallbacks))
+	for dir := range o.fallbacks {
+		dirs = append(dirs, string(dir))
 	}
-	approvers := o.entries(path, o.approvers, o.options)
-	o.memoizedApprovers.store(path, approvers)
-	return approvers
+	sort.Strings(dirs)
+	return dirs
+}
+
+// WalkErrors returns the errors in walking the file tree of the
+// repository, which are recorded with WalkErrorWarn policy.
+// The subtrees with the errors have no OWNERS configuration loaded.
+func (o *Owners) WalkErrors() []*WalkError {
+	return o.walkErrors
+}
+
+// Approvers returns a set of approvers for given path.
+// The path is looked up as a directory and also matched against the
+// filters. Use ApproversForFile or ApproversForDir to tell them apart.
+func (o *Owners) Approvers(path string) UsernameSet {
+	return o.lookup(&o.memoizedApprovers, newRepoPath(path), anyPath, o.approvers, o.approverFilters, o.emeritusApprovers)
+}
+
+// ApproversForFile returns a set of approvers for given file path.
+// The file is looked up from its containing directory.
+func (o *Owners) ApproversForFile(file string) UsernameSet {
+	return o.lookup(&o.memoizedApprovers, newRepoPath(file), filePath, o.approvers, o.approverFilters, o.emeritusApprovers)
+}
+
+// ApproversForDir returns a set of approvers for given directory path.
+// The filters, which are for files, are not used.
+func (o *Owners) ApproversForDir(dir string) UsernameSet {
+	return o.lookup(&o.memoizedApprovers, newRepoPath(dir), dirPath, o.approvers, o.approverFilters, o.emeritusApprovers)
 }
 
-// IsApprover returns true if given user is an approver for given file path.
+// IsApprover returns true if given user is an approver for given path.
 func (o *Owners) IsApprover(user, path string) bool {
 	approvers := o.Approvers(path)
 	return approvers.Has(user)
 }
 
-// Reviewers returns a set of reviewers for given file path.
+// Reviewers returns a set of reviewers for given path.
+// The path is looked up in the same way as Approvers.
 func (o *Owners) Reviewers(path string) UsernameSet {
-	if reviewers := o.memoizedReviewers.load(path); reviewers != nil {
-		return reviewers
-	}
-	reviewers := o.entries(path, o.reviewers, o.options)
-	o.memoizedReviewers.store(path, reviewers)
-	return reviewers
+	return o.lookup(&o.memoizedReviewers, newRepoPath(path), anyPath, o.reviewers, o.reviewerFilters, o.emeritusReviewers)
+}
+
+// ReviewersForFile returns a set of reviewers for given file path.
+func (o *Owners) ReviewersForFile(file string) UsernameSet {
+	return o.lookup(&o.memoizedReviewers, newRepoPath(file), filePath, o.reviewers, o.reviewerFilters, o.emeritusReviewers)
 }
 
-// IsReviewer returns true if given user is a reviewer for given file path.
+// ReviewersForDir returns a set of reviewers for given directory path.
+func (o *Owners) ReviewersForDir(dir string) UsernameSet {
+	return o.lookup(&o.memoizedReviewers, newRepoPath(dir), dirPath, o.reviewers, o.reviewerFilters, o.emeritusReviewers)
+}
+
+// IsReviewer returns true if given user is a reviewer for given path.
 func (o *Owners) IsReviewer(user, path string) bool {
 	reviewers := o.Reviewers(path)
 	return reviewers.Has(user)
 }
 
-// RequiredReviewers returns a set of required reviewers for given file path.
+// RequiredReviewers returns a set of required reviewers for given path.
+// The path is looked up in the same way as Approvers.
 func (o *Owners) RequiredReviewers(path string) UsernameSet {
-	if requiredReviewers := o.memoizedRequiredReviewers.load(path); requiredReviewers != nil {
-		return requiredReviewers
-	}
-	requiredReviewers := o.entries(path, o.requiredReviewers, o.options)
-	o.memoizedRequiredReviewers.store(path, requiredReviewers)
-	return requiredReviewers
+	return o.lookup(&o.memoizedRequiredReviewers, newRepoPath(path), anyPath, o.requiredReviewers, o.requiredReviewerFilters, nil)
+}
+
+// RequiredReviewersForFile returns a set of required reviewers for given file path.
+func (o *Owners) RequiredReviewersForFile(file string) UsernameSet {
+	return o.lookup(&o.memoizedRequiredReviewers, newRepoPath(file), filePath, o.requiredReviewers, o.requiredReviewerFilters, nil)
+}
+
+// RequiredReviewersForDir returns a set of required reviewers for given directory path.
+func (o *Owners) RequiredReviewersForDir(dir string) UsernameSet {
+	return o.lookup(&o.memoizedRequiredReviewers, newRepoPath(dir), dirPath, o.requiredReviewers, o.requiredReviewerFilters, nil)
 }
 
 // IsRequiredReviewer returns true if given user is a required reviewer for given path.
@@ -235,15 +379,83 @@ func (o *Owners) IsRequiredReviewer(user, path string) bool {
 	return requiredReviewers.Has(user)
 }
 
+// EmeritusApprovers returns a set of emeritus approvers for given file path.
+// Emeritus approvers are not included in Approvers.
+func (o *Owners) EmeritusApprovers(path string) UsernameSet {
+	p := newRepoPath(path)
+	return o.entries(p, p, o.emeritusApprovers, nil, nil, o.options)
+}
+
+// EmeritusReviewers returns a set of emeritus reviewers for given file path.
+// Emeritus reviewers are not included in Reviewers.
+func (o *Owners) EmeritusReviewers(path string) UsernameSet {
+	p := newRepoPath(path)
+	return o.entries(p, p, o.emeritusReviewers, nil, nil, o.options)
+}
+
+// Labels returns a sorted list of labels for given path.
+// Like the owners, labels are inherited from the parent directories.
+// The path is looked up in the same way as Approvers.
+func (o *Owners) Labels(path string) []string {
+	p := newRepoPath(path)
+	labels := map[string]bool{}
+	o.collectLabels(p, p, labels)
+	return sortedKeys(labels)
+}
+
+// collectLabels adds the labels applying to path in the chain of start.
+func (o *Owners) collectLabels(start, path repoPath, labels map[string]bool) {
+	for _, dir := range o.chain(start, o.options) {
+		for _, label := range o.labels[dir] {
+			labels[label] = true
+		}
+		if filters := o.labelFilters[dir]; len(filters) > 0 {
+			rel := path.rel(dir)
+			for _, f := range filters {
+				if !f.pattern.MatchString(rel) {
+					continue
+				}
+				for _, label := range f.labels {
+					labels[label] = true
+				}
+			}
+		}
+	}
+}
+
+// LabelsForFiles returns a sorted list of labels for any of given files.
+// Each file is looked up from its containing directory.
+func (o *Owners) LabelsForFiles(files []string) []string {
+	labels := map[string]bool{}
+	for _, file := range files {
+		p := newRepoPath(file)
+		o.collectLabels(p.parent(), p, labels)
+	}
+	return sortedKeys(labels)
+}
+
 type options struct {
 	NoInheritance bool `yaml:"no_inherit,omitempty"`
 }
 
 type ownersConfig struct {
-	Options           options  `yaml:",inline"`
+	Options           options                 `yaml:",inline"`
+	Approvers         []string                `yaml:"approvers,omitempty"`
+	Reviewers         []string                `yaml:"reviewers,omitempty"`
+	RequiredReviewers []string                `yaml:"required_reviewers,omitempty"`
+	Filters           map[string]filterConfig `yaml:"filters,omitempty"`
+	EmeritusApprovers []string                `yaml:"emeritus_approvers,omitempty"`
+	EmeritusReviewers []string                `yaml:"emeritus_reviewers,omitempty"`
+	Labels            []string                `yaml:"labels,omitempty"`
+}
+
+// filterConfig is the owners of the files matching a pattern
+// in the filters section.
+type filterConfig struct {
 	Approvers         []string `yaml:"approvers,omitempty"`
 	Reviewers         []string `yaml:"reviewers,omitempty"`
 	RequiredReviewers []string `yaml:"required_reviewers,omitempty"`
+	Labels            []string `yaml:"labels,omitempty"`
 }
 
 type aliasesConfig struct {
@@ -2,22 +2,46 @@ package repoowners
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
+	gogit "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 func newMemFS() *afero.Afero {
 	return &afero.Afero{Fs: afero.NewMemMapFs()}
 }
 
+// writeFiles writes files, which map the paths relative to base
+// to their contents, into fs.
+func writeFiles(t *testing.T, fs *afero.Afero, base string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(base, name)
+		if err := fs.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestLoadLocalOnlyOneOwners(t *testing.T) {
 	const basePath = "src/github.com/nasa9084/test_repository"
-	fs = newMemFS()
+	fs := newMemFS()
 	if err := fs.MkdirAll(basePath, 0755); err != nil {
 		t.Fatal(err)
 	}
@@ -38,15 +62,15 @@ reviewers:
 	if err := f.Close(); err != nil {
 		t.Fatal(err)
 	}
-	o, err := LoadLocal(basePath)
+	o, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	wantApprovers := map[string]UsernameSet{
-		".": newUsernameSet("alice", "bob"),
+	wantApprovers := map[repoPath]UsernameSet{
+		"": newUsernameSet("alice", "bob"),
 	}
-	wantReviewers := map[string]UsernameSet{
-		".": newUsernameSet("charlie", "dave", "ellen"),
+	wantReviewers := map[repoPath]UsernameSet{
+		"": newUsernameSet("charlie", "dave", "ellen"),
 	}
 	if !reflect.DeepEqual(o.approvers, wantApprovers) {
 		t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", o.approvers, wantApprovers)
@@ -60,7 +84,7 @@ reviewers:
 
 func TestLoadLocalOnlyAliases(t *testing.T) {
 	const basePath = "src/github.com/nasa9084/test_repository"
-	fs = newMemFS()
+	fs := newMemFS()
 	if err := fs.MkdirAll(basePath, 0755); err != nil {
 		t.Fatal(err)
 	}
@@ -81,7 +105,7 @@ aliases:
 		t.Fatal(err)
 	}
 
-	o, err := LoadLocal(basePath)
+	o, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -95,9 +119,153 @@ aliases:
 	}
 }
 
+func TestLoadCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repoowners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := gogit.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, dir, map[string]string{
+		DefaultAliasesFilename: "aliases:\n  admins:\n  - alice\n",
+		DefaultOwnersFilename:  "approvers:\n- admins\n",
+		"foo/OWNERS":           "approvers:\n- bob\n",
+		"foo/bar.go":           "package foo\n",
+	})
+	// uncommitted changes must not be loaded
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo", "OWNERS"), []byte("approvers:\n- charlie\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := LoadCommit(dir, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantApprovers := map[repoPath]UsernameSet{
+		"":    newUsernameSet("admins"),
+		"foo": newUsernameSet("bob"),
+	}
+	if !reflect.DeepEqual(o.approvers, wantApprovers) {
+		t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", o.approvers, wantApprovers)
+		return
+	}
+	wantAliases := map[string]UsernameSet{
+		"admins": newUsernameSet("alice"),
+	}
+	if !reflect.DeepEqual(o.aliases, wantAliases) {
+		t.Errorf("unexpected aliases:\n  got:  %+v\n  want: %+v", o.aliases, wantAliases)
+		return
+	}
+
+	if _, err := LoadCommit(dir, "no-such-branch"); err == nil {
+		t.Error("error should be returned")
+		return
+	} else if _, ok := err.(*RefNotFoundError); !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LoadCommitContext(ctx, dir, "master"); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
+
+func TestLoadRemote(t *testing.T) {
+	remote, err := ioutil.TempDir("", "repoowners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	dir := filepath.Join(remote, "foo", "bar")
+	r, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, dir, map[string]string{
+		DefaultOwnersFilename: "approvers:\n- alice\n",
+	})
+	if _, err := r.CreateTag("v1", first, nil); err != nil {
+		t.Fatal(err)
+	}
+	second := commitFiles(t, dir, map[string]string{
+		DefaultOwnersFilename: "approvers:\n- bob\n",
+	})
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("feature"), second)); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, dir, map[string]string{
+		DefaultOwnersFilename: "approvers:\n- charlie\n",
+	})
+
+	l := NewLoader()
+	defer l.Clean()
+	tests := []struct {
+		ref  string
+		want UsernameSet
+	}{
+		{ref: "master", want: newUsernameSet("charlie")},
+		{ref: "feature", want: newUsernameSet("bob")},
+		{ref: "v1", want: newUsernameSet("alice")},
+		{ref: first.String(), want: newUsernameSet("alice")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			o, err := l.LoadRemote(remote, "foo", "bar", tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := o.Approvers(""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", got, tt.want)
+				return
+			}
+		})
+	}
+
+	_, err = l.LoadRemote(remote, "foo", "bar", "no-such-ref")
+	if e, ok := err.(*RefNotFoundError); !ok {
+		t.Errorf("unexpected error: %v", err)
+		return
+	} else if e.Ref != "no-such-ref" {
+		t.Errorf("unexpected ref: %s", e.Ref)
+		return
+	}
+}
+
+// commitFiles writes files into the worktree of the git repository
+// at dir, and commits them.
+func commitFiles(t *testing.T, dir string, files map[string]string) plumbing.Hash {
+	t.Helper()
+	r, err := gogit.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, &afero.Afero{Fs: afero.NewOsFs()}, dir, files)
+	for name := range files {
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig := &object.Signature{Name: "alice", Email: "alice@example.com", When: time.Now()}
+	hash, err := wt.Commit("commit", &gogit.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
 func TestApprovers(t *testing.T) {
 	owners := Owners{
-		approvers: map[string]UsernameSet{
+		approvers: map[repoPath]UsernameSet{
 			"foo/bar":     newUsernameSet("bob"),
 			"foo/bar/baz": newUsernameSet("charlie", "dave"),
 			"foo/bar/qux": newUsernameSet("ellen"),
@@ -133,12 +301,12 @@ func TestApprovers(t *testing.T) {
 
 func TestApproversWithNoInheritance(t *testing.T) {
 	owners := Owners{
-		options: map[string]options{
+		options: map[repoPath]options{
 			"foo/bar/baz": options{
 				NoInheritance: true,
 			},
 		},
-		approvers: map[string]UsernameSet{
+		approvers: map[repoPath]UsernameSet{
 			"foo/bar":          newUsernameSet("alice"),
 			"foo/bar/baz":      newUsernameSet("bob"),
 			"foo/bar/qux":      newUsernameSet("charlie"),
@@ -186,7 +354,7 @@ func TestApproversWithNoInheritance(t *testing.T) {
 
 func TestApproversWithAliases(t *testing.T) {
 	owners := Owners{
-		approvers: map[string]UsernameSet{
+		approvers: map[repoPath]UsernameSet{
 			"foo/bar":      newUsernameSet("alice", "admins"),
 			"foo/bar/baz":  newUsernameSet("bob", "members"),
 			"foo/qux":      newUsernameSet("alice"),
@@ -224,9 +392,253 @@ func TestApproversWithAliases(t *testing.T) {
 	}
 }
 
+func TestApproversWithFilters(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS": `approvers:
+- alice
+filters:
+  "\\.go$":
+    approvers:
+    - bob
+  "^docs/.*\\.md$":
+    approvers:
+    - charlie
+    required_reviewers:
+    - ellen
+`,
+		"foo/OWNERS": `filters:
+  "_test\\.go$":
+    approvers:
+    - dave
+`,
+	}
+	writeFiles(t, fs, basePath, files)
+	owners, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// patterns are matched against the path relative to the OWNERS file
+	tests := []struct {
+		got  UsernameSet
+		want UsernameSet
+	}{
+		{
+			got:  owners.Approvers("main.go"),
+			want: newUsernameSet("alice", "bob"),
+		},
+		{
+			got:  owners.Approvers("README.md"),
+			want: newUsernameSet("alice"),
+		},
+		{
+			got:  owners.Approvers("docs/README.md"),
+			want: newUsernameSet("alice", "charlie"),
+		},
+		{
+			got:  owners.Approvers("foo/docs/README.md"),
+			want: newUsernameSet("alice"),
+		},
+		{
+			got:  owners.Approvers("foo/foo_test.go"),
+			want: newUsernameSet("alice", "bob", "dave"),
+		},
+		{
+			got:  owners.Approvers("foo/foo.go"),
+			want: newUsernameSet("alice", "bob"),
+		},
+		{
+			got:  owners.RequiredReviewers("docs/README.md"),
+			want: newUsernameSet("ellen"),
+		},
+		{
+			got:  owners.RequiredReviewers("main.go"),
+			want: newUsernameSet(),
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("unexpected owners:\n  got:  %+v\n  want: %+v", tt.got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestFileAndDirLookups(t *testing.T) {
+	re := regexp.MustCompile(`\.go$`)
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":               newUsernameSet("alice"),
+			"pkg/foo":        newUsernameSet("bob"),
+			"pkg/foo/bar.go": newUsernameSet("charlie"),
+		},
+		approverFilters: map[repoPath][]filteredSet{
+			"": {{re, newUsernameSet("dave")}},
+		},
+		options: map[repoPath]options{
+			"pkg/foo/bar.go": {NoInheritance: true},
+		},
+	}
+	tests := []struct {
+		label string
+		got   UsernameSet
+		want  UsernameSet
+	}{
+		{
+			label: "file",
+			got:   owners.ApproversForFile("pkg/foo/bar.go"),
+			want:  newUsernameSet("alice", "bob", "dave"),
+		},
+		{
+			label: "directory named like a file",
+			got:   owners.ApproversForDir("pkg/foo/bar.go"),
+			want:  newUsernameSet("charlie"),
+		},
+		{
+			label: "directory",
+			got:   owners.ApproversForDir("pkg/foo"),
+			want:  newUsernameSet("alice", "bob"),
+		},
+		{
+			label: "file at root",
+			got:   owners.ApproversForFile("main.go"),
+			want:  newUsernameSet("alice", "dave"),
+		},
+		{
+			label: "root",
+			got:   owners.ApproversForDir(""),
+			want:  newUsernameSet("alice"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("unexpected approvers: %v != %v", tt.got, tt.want)
+				return
+			}
+		})
+	}
+
+	status := owners.Approval([]string{"pkg/foo/bar.go"}, []string{"bob"}, nil)
+	if !status.Approved || status.Dirs[0].Dir != "pkg/foo" {
+		t.Errorf("unexpected approval status: %+v", status)
+		return
+	}
+}
+
+func TestEmeritus(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"foo":     newUsernameSet("alice", "admins"),
+			"foo/bar": newUsernameSet("bob"),
+		},
+		reviewers: map[repoPath]UsernameSet{
+			"foo": newUsernameSet("charlie"),
+		},
+		emeritusApprovers: map[repoPath]UsernameSet{
+			"foo":     newUsernameSet("dave"),
+			"foo/bar": newUsernameSet("ellen"),
+		},
+		emeritusReviewers: map[repoPath]UsernameSet{
+			"foo": newUsernameSet("frank"),
+		},
+		aliases: map[string]UsernameSet{
+			"admins": newUsernameSet("dave", "george"),
+		},
+	}
+	tests := []struct {
+		got  UsernameSet
+		want UsernameSet
+	}{
+		{
+			got:  owners.Approvers("foo/bar"),
+			want: newUsernameSet("alice", "bob", "george"),
+		},
+		{
+			got:  owners.EmeritusApprovers("foo/bar"),
+			want: newUsernameSet("dave", "ellen"),
+		},
+		{
+			got:  owners.EmeritusApprovers("foo"),
+			want: newUsernameSet("dave"),
+		},
+		{
+			got:  owners.Reviewers("foo/bar"),
+			want: newUsernameSet("charlie"),
+		},
+		{
+			got:  owners.EmeritusReviewers("foo/bar"),
+			want: newUsernameSet("frank"),
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("unexpected owners:\n  got:  %+v\n  want: %+v", tt.got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	owners := Owners{
+		labels: map[repoPath][]string{
+			"":        {"sig/all"},
+			"foo":     {"area/foo"},
+			"foo/bar": {"area/bar", "area/foo"},
+			"qux":     {"area/qux"},
+		},
+		labelFilters: map[repoPath][]filteredLabels{
+			"foo": {
+				{pattern: regexp.MustCompile(`\.md$`), labels: []string{"kind/documentation"}},
+			},
+		},
+		options: map[repoPath]options{
+			"qux": {NoInheritance: true},
+		},
+	}
+	tests := []struct {
+		got  []string
+		want []string
+	}{
+		{
+			got:  owners.Labels("main.go"),
+			want: []string{"sig/all"},
+		},
+		{
+			got:  owners.Labels("foo/bar/main.go"),
+			want: []string{"area/bar", "area/foo", "sig/all"},
+		},
+		{
+			got:  owners.Labels("foo/bar/README.md"),
+			want: []string{"area/bar", "area/foo", "kind/documentation", "sig/all"},
+		},
+		{
+			got:  owners.Labels("qux/main.go"),
+			want: []string{"area/qux"},
+		},
+		{
+			got:  owners.LabelsForFiles([]string{"foo/main.go", "qux/main.go"}),
+			want: []string{"area/foo", "area/qux", "sig/all"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("unexpected labels:\n  got:  %+v\n  want: %+v", tt.got, tt.want)
+				return
+			}
+		})
+	}
+}
+
 func TestIsApprover(t *testing.T) {
 	owners := Owners{
-		approvers: map[string]UsernameSet{
+		approvers: map[repoPath]UsernameSet{
 			"foo/bar":     newUsernameSet("alice"),
 			"foo/bar/baz": newUsernameSet("bob"),
 		},
@@ -274,6 +686,70 @@ func TestIsApprover(t *testing.T) {
 	}
 }
 
+func TestCaseInsensitive(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS_ALIASES": "aliases:\n  Admins:\n  - Alice\n  - BOB\n",
+		"OWNERS":         "approvers:\n- ADMINS\n- Charlie\n- charlie\n",
+	}
+	writeFiles(t, fs, basePath, files)
+	o, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"alice", "ALICE", "bob", "Bob", "charlie", "CHARLIE"} {
+		if !o.IsApprover(user, "main.go") {
+			t.Errorf("%s should be an approver", user)
+		}
+	}
+	if o.IsApprover("admins", "main.go") {
+		t.Error("alias should be expanded")
+	}
+
+	got := o.Approvers("main.go").List()
+	sort.Strings(got)
+	want := []string{"Alice", "BOB", "Charlie"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected approvers:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
+
+func TestCanonicalPaths(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":         "approvers:\n- alice\n",
+		"foo/bar/OWNERS": "approvers:\n- bob\n",
+	}
+	writeFiles(t, fs, basePath, files)
+	o, err := NewLoader(WithFs(fs)).LoadLocal(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want UsernameSet
+	}{
+		{"", newUsernameSet("alice")},
+		{".", newUsernameSet("alice")},
+		{"/", newUsernameSet("alice")},
+		{"main.go", newUsernameSet("alice")},
+		{"foo/main.go", newUsernameSet("alice")},
+		{"foo/bar/main.go", newUsernameSet("alice", "bob")},
+		{"./foo/bar/", newUsernameSet("alice", "bob")},
+		{"foo//bar", newUsernameSet("alice", "bob")},
+		{"/foo/bar", newUsernameSet("alice", "bob")},
+		{filepath.Join("foo", "bar", "main.go"), newUsernameSet("alice", "bob")},
+	}
+	for _, tt := range tests {
+		if got := o.Approvers(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unexpected approvers of %q: %v != %v", tt.path, got, tt.want)
+		}
+	}
+}
+
 func TestParseOwners(t *testing.T) {
 	tests := []struct {
 		label string
@@ -320,6 +796,63 @@ reviewers:
 				Reviewers: []string{"bob"},
 			},
 		},
+		{
+			label: "with filters",
+			in: `approvers:
+- alice
+filters:
+  "\\.go$":
+    approvers:
+    - bob
+    reviewers:
+    - charlie
+  "^docs/.*\\.md$":
+    required_reviewers:
+    - dave`,
+			want: ownersConfig{
+				Approvers: []string{"alice"},
+				Filters: map[string]filterConfig{
+					`\.go$`: {
+						Approvers: []string{"bob"},
+						Reviewers: []string{"charlie"},
+					},
+					`^docs/.*\.md$`: {
+						RequiredReviewers: []string{"dave"},
+					},
+				},
+			},
+		},
+		{
+			label: "with emeritus",
+			in: `approvers:
+- alice
+emeritus_approvers:
+- bob
+emeritus_reviewers:
+- charlie`,
+			want: ownersConfig{
+				Approvers:         []string{"alice"},
+				EmeritusApprovers: []string{"bob"},
+				EmeritusReviewers: []string{"charlie"},
+			},
+		},
+		{
+			label: "with labels",
+			in: `labels:
+- area/foo
+filters:
+  "\\.md$":
+    labels:
+    - kind/documentation`,
+			want: ownersConfig{
+				Labels: []string{"area/foo"},
+				Filters: map[string]filterConfig{
+					`\.md$`: {
+						Labels: []string{"kind/documentation"},
+					},
+				},
+			},
+		},
 		{
 			label: "with comment",
 			in: `---
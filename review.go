@@ -0,0 +1,173 @@
+package repoowners
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// SelectReviewersOptions are the options for Owners.SelectReviewers.
+type SelectReviewersOptions struct {
+	// Author is the author of the change, who is never selected.
+	Author string
+	// Busy is the list of users who should not be selected.
+	Busy []string
+	// Rand is the source of randomness. If nil, a source seeded
+	// with the current time is used.
+	Rand *rand.Rand
+}
+
+// SelectReviewers randomly selects up to n reviewers for given changed
+// files from their reviewers. The chance to be selected is weighted by
+// how many of the files a reviewer covers and how deep the OWNERS files
+// listing the reviewer are, so that the reviewers closest to the change
+// are preferred while the load is spread over everybody.
+// The selected reviewers are returned in the order of selection.
+func (o *Owners) SelectReviewers(files []string, n int, opts SelectReviewersOptions) []string {
+	excluded := newUsernameSet(opts.Busy...)
+	if opts.Author != "" {
+		excluded.Add(opts.Author)
+	}
+	r := opts.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	weights := map[string]int{}
+	names := UsernameSet{}
+	for _, file := range files {
+		// weight of each reviewer for this file,
+		// which is the depth of the deepest OWNERS file
+		fileWeights := map[string]int{}
+		p := newRepoPath(file)
+		for _, dir := range o.chain(p.parent(), o.options) {
+			w := dir.depth() + 1
+			for key, name := range o.activeAt(dir, p, o.reviewers, o.reviewerFilters, o.emeritusReviewers) {
+				if excluded.Has(name) {
+					continue
+				}
+				names.Add(name)
+				if w > fileWeights[key] {
+					fileWeights[key] = w
+				}
+			}
+		}
+		for key, w := range fileWeights {
+			weights[key] += w
+		}
+	}
+
+	candidates := names.List()
+	var selected []string
+	for len(selected) < n && len(candidates) > 0 {
+		total := 0
+		for _, c := range candidates {
+			total += weights[normalizeName(c)]
+		}
+		x := r.Intn(total)
+		i := 0
+		for ; i < len(candidates)-1; i++ {
+			x -= weights[normalizeName(candidates[i])]
+			if x < 0 {
+				break
+			}
+		}
+		selected = append(selected, candidates[i])
+		candidates = append(candidates[:i], candidates[i+1:]...)
+	}
+	return selected
+}
+
+// RequiredReviewStatus is the status of the required reviews
+// for a set of changed files.
+type RequiredReviewStatus struct {
+	// Satisfied is true if no directory is blocking.
+	Satisfied bool
+	// Dirs is the status of each OWNERS directory which has
+	// required_reviewers for the files, ordered by path.
+	Dirs []RequiredReview
+}
+
+// BlockingDirs returns the OWNERS directories which still need
+// a review by one of their required reviewers.
+func (s RequiredReviewStatus) BlockingDirs() []string {
+	var ret []string
+	for _, d := range s.Dirs {
+		if !d.Satisfied() {
+			ret = append(ret, d.Dir)
+		}
+	}
+	return ret
+}
+
+// RequiredReview is the required review status of one OWNERS directory.
+type RequiredReview struct {
+	// Dir is the directory of the OWNERS file. The root directory is "".
+	Dir string
+	// Files are the changed files the OWNERS file applies to.
+	Files []string
+	// UnreviewedFiles are the files in Files which are not reviewed
+	// by their required reviewers yet.
+	UnreviewedFiles []string
+	// RequiredReviewers is the set of required reviewers listed
+	// in the OWNERS file for any of the files.
+	RequiredReviewers UsernameSet
+	// ReviewedBy is the set of users in RequiredReviewers who have reviewed.
+	ReviewedBy UsernameSet
+}
+
+// Satisfied returns true if all the files are reviewed
+// by at least one of their required reviewers.
+func (r RequiredReview) Satisfied() bool {
+	return len(r.UnreviewedFiles) == 0
+}
+
+// RequiredReviews returns the status of the required reviews for given
+// changed files, given the users who have reviewed them.
+// Every OWNERS file which applies to one of the files and has
+// required_reviewers for it needs a review by one of them, including
+// the ones in the parent directories.
+func (o *Owners) RequiredReviews(files []string, reviewedBy []string) RequiredReviewStatus {
+	reviewed := newUsernameSet(reviewedBy...)
+	dirs := map[repoPath]*RequiredReview{}
+	for _, file := range files {
+		p := newRepoPath(file)
+		for _, dir := range o.chain(p.parent(), o.options) {
+			entries := entriesAt(dir, p, o.requiredReviewers, o.requiredReviewerFilters)
+			if len(entries) == 0 {
+				continue
+			}
+			d, ok := dirs[dir]
+			if !ok {
+				d = &RequiredReview{
+					Dir:               string(dir),
+					RequiredReviewers: UsernameSet{},
+					ReviewedBy:        UsernameSet{},
+				}
+				dirs[dir] = d
+			}
+			required := o.expandAliases(entries)
+			reviewedFile := required.Intersection(reviewed)
+			d.Files = append(d.Files, file)
+			if len(reviewedFile) == 0 {
+				d.UnreviewedFiles = append(d.UnreviewedFiles, file)
+			}
+			d.RequiredReviewers = d.RequiredReviewers.Union(required)
+			d.ReviewedBy = d.ReviewedBy.Union(reviewedFile)
+		}
+	}
+
+	status := RequiredReviewStatus{Satisfied: true}
+	for _, d := range dirs {
+		sort.Strings(d.Files)
+		sort.Strings(d.UnreviewedFiles)
+		status.Dirs = append(status.Dirs, *d)
+		if !d.Satisfied() {
+			status.Satisfied = false
+		}
+	}
+	sort.Slice(status.Dirs, func(i, j int) bool {
+		return status.Dirs[i].Dir < status.Dirs[j].Dir
+	})
+	return status
+}
@@ -0,0 +1,150 @@
+package repoowners
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSelectReviewers(t *testing.T) {
+	owners := Owners{
+		reviewers: map[repoPath]UsernameSet{
+			"":        newUsernameSet("alice", "bob"),
+			"foo":     newUsernameSet("charlie", "members"),
+			"foo/bar": newUsernameSet("dave"),
+		},
+		aliases: map[string]UsernameSet{
+			"members": newUsernameSet("ellen", "frank"),
+		},
+	}
+	files := []string{"foo/bar/a.go", "foo/b.go"}
+
+	got := owners.SelectReviewers(files, 10, SelectReviewersOptions{
+		Author: "Charlie",
+		Busy:   []string{"bob"},
+		Rand:   rand.New(rand.NewSource(0)),
+	})
+	if len(got) != 4 {
+		t.Errorf("unexpected number of reviewers: %v", got)
+		return
+	}
+	if selected := newUsernameSet(got...); !reflect.DeepEqual(selected, newUsernameSet("alice", "dave", "ellen", "frank")) {
+		t.Errorf("unexpected reviewers: %v", got)
+		return
+	}
+
+	// deterministic with the same source
+	for seed := int64(0); seed < 10; seed++ {
+		opts := SelectReviewersOptions{Rand: rand.New(rand.NewSource(seed))}
+		got := owners.SelectReviewers(files, 2, opts)
+		opts.Rand = rand.New(rand.NewSource(seed))
+		if again := owners.SelectReviewers(files, 2, opts); !reflect.DeepEqual(got, again) {
+			t.Errorf("not deterministic: %v != %v", got, again)
+			return
+		}
+		if len(got) != 2 {
+			t.Errorf("unexpected number of reviewers: %v", got)
+			return
+		}
+	}
+
+	// nearer reviewers are selected more often
+	counts := map[string]int{}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 1000; i++ {
+		got := owners.SelectReviewers(files, 1, SelectReviewersOptions{Rand: r})
+		counts[got[0]]++
+	}
+	if counts["charlie"] <= counts["alice"] {
+		t.Errorf("charlie should be selected more often than alice: %v", counts)
+		return
+	}
+	if counts["dave"] <= counts["alice"] {
+		t.Errorf("dave should be selected more often than alice: %v", counts)
+		return
+	}
+}
+
+func TestRequiredReviews(t *testing.T) {
+	owners := Owners{
+		requiredReviewers: map[repoPath]UsernameSet{
+			"":        newUsernameSet("alice"),
+			"foo/bar": newUsernameSet("security"),
+			"qux":     newUsernameSet("charlie"),
+		},
+		options: map[repoPath]options{
+			"qux": {NoInheritance: true},
+		},
+		aliases: map[string]UsernameSet{
+			"security": newUsernameSet("bob", "dave"),
+		},
+	}
+	files := []string{"foo/bar/a.go", "foo/b.go", "qux/c.go"}
+
+	tests := []struct {
+		label         string
+		reviewedBy    []string
+		wantSatisfied bool
+		wantBlocking  []string
+	}{
+		{
+			label:         "nobody",
+			reviewedBy:    nil,
+			wantSatisfied: false,
+			wantBlocking:  []string{"", "foo/bar", "qux"},
+		},
+		{
+			label:         "parent required reviewer does not satisfy child",
+			reviewedBy:    []string{"alice", "charlie"},
+			wantSatisfied: false,
+			wantBlocking:  []string{"foo/bar"},
+		},
+		{
+			label:         "all",
+			reviewedBy:    []string{"Alice", "Dave", "charlie", "ellen"},
+			wantSatisfied: true,
+			wantBlocking:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got := owners.RequiredReviews(files, tt.reviewedBy)
+			if got.Satisfied != tt.wantSatisfied {
+				t.Errorf("%t != %t", got.Satisfied, tt.wantSatisfied)
+				return
+			}
+			if !reflect.DeepEqual(got.BlockingDirs(), tt.wantBlocking) {
+				t.Errorf("unexpected blocking dirs:\n  got:  %+v\n  want: %+v", got.BlockingDirs(), tt.wantBlocking)
+				return
+			}
+		})
+	}
+
+	got := owners.RequiredReviews(files, []string{"dave"})
+	want := []RequiredReview{
+		{
+			Dir:               "",
+			Files:             []string{"foo/b.go", "foo/bar/a.go"},
+			UnreviewedFiles:   []string{"foo/b.go", "foo/bar/a.go"},
+			RequiredReviewers: newUsernameSet("alice"),
+			ReviewedBy:        newUsernameSet(),
+		},
+		{
+			Dir:               "foo/bar",
+			Files:             []string{"foo/bar/a.go"},
+			RequiredReviewers: newUsernameSet("bob", "dave"),
+			ReviewedBy:        newUsernameSet("dave"),
+		},
+		{
+			Dir:               "qux",
+			Files:             []string{"qux/c.go"},
+			UnreviewedFiles:   []string{"qux/c.go"},
+			RequiredReviewers: newUsernameSet("charlie"),
+			ReviewedBy:        newUsernameSet(),
+		},
+	}
+	if !reflect.DeepEqual(got.Dirs, want) {
+		t.Errorf("unexpected required reviews:\n  got:  %+v\n  want: %+v", got.Dirs, want)
+		return
+	}
+}
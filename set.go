@@ -7,7 +7,16 @@ import (
 )
 
 // UsernameSet is a set type for usernames.
-type UsernameSet map[string]struct{}
+// Usernames are case-insensitive: the keys are case-folded usernames
+// and the values are their display forms, which are the forms they
+// are first added in.
+type UsernameSet map[string]string
+
+// normalizeName returns the case-folded form of given username
+// or alias name.
+func normalizeName(name string) string {
+	return strings.ToLower(name)
+}
 
 func newUsernameSet(usernames ...string) UsernameSet {
 	us := UsernameSet{}
@@ -19,7 +28,10 @@ func newUsernameSet(usernames ...string) UsernameSet {
 // This function is mutable.
 func (us UsernameSet) Add(usernames ...string) {
 	for _, username := range usernames {
-		us[username] = struct{}{}
+		key := normalizeName(username)
+		if _, ok := us[key]; !ok {
+			us[key] = username
+		}
 	}
 }
 
@@ -27,7 +39,7 @@ func (us UsernameSet) Add(usernames ...string) {
 // This function is mutable.
 func (us UsernameSet) Delete(usernames ...string) {
 	for _, username := range usernames {
-		delete(us, username)
+		delete(us, normalizeName(username))
 	}
 }
 
@@ -36,26 +48,45 @@ func (us UsernameSet) Delete(usernames ...string) {
 // This function is immutable.
 func (us UsernameSet) Union(us2 UsernameSet) UsernameSet {
 	result := UsernameSet{}
-	for k := range us {
-		result.Add(k)
+	for _, v := range us {
+		result.Add(v)
 	}
-	for k := range us2 {
-		result.Add(k)
+	for _, v := range us2 {
+		result.Add(v)
+	}
+	return result
+}
+
+// Intersection get a new set which contains the members of the set
+// which are also members of given set.
+// This function is immutable.
+func (us UsernameSet) Intersection(us2 UsernameSet) UsernameSet {
+	result := UsernameSet{}
+	for k, v := range us {
+		if _, ok := us2[k]; ok {
+			result.Add(v)
+		}
 	}
 	return result
 }
 
 // Has returns true if given username is a member of the set.
 func (us UsernameSet) Has(username string) bool {
-	_, has := us[username]
+	_, has := us[normalizeName(username)]
 	return has
 }
 
-// List returns a sorted list which contains members of set.
+// List returns a list which contains the display forms of members of set,
+// sorted case-insensitively.
 func (us UsernameSet) List() []string {
-	ret := make([]string, 0, len(us))
+	keys := make([]string, 0, len(us))
 	for k := range us {
-		ret = append(ret, k)
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]string, 0, len(us))
+	for _, k := range keys {
+		ret = append(ret, us[k])
 	}
 	return ret
 }
@@ -70,9 +101,9 @@ func (us UsernameSet) Copy() UsernameSet {
 // is a member of the set, otherwise false as second
 // return value..
 func (us UsernameSet) Pop() (string, bool) {
-	for key := range us {
-		us.Delete(key)
-		return key, true
+	for key, username := range us {
+		delete(us, key)
+		return username, true
 	}
 	return "", false
 }
@@ -0,0 +1,112 @@
+package repoowners
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// source provides read access to the files of a repository.
+// All paths are relative to the repository root, which is ".".
+// A source is safe for concurrent use.
+type source interface {
+	// ReadDir returns the entries of the named directory.
+	ReadDir(dir string) ([]os.FileInfo, error)
+	// Open opens the named file for reading.
+	Open(path string) (io.ReadCloser, error)
+}
+
+// fsSource is a source backed by a directory on the filesystem.
+type fsSource struct {
+	fs   *afero.Afero
+	base string
+}
+
+func (s fsSource) ReadDir(dir string) ([]os.FileInfo, error) {
+	return s.fs.ReadDir(filepath.Join(s.base, dir))
+}
+
+func (s fsSource) Open(path string) (io.ReadCloser, error) {
+	return s.fs.Open(filepath.Join(s.base, path))
+}
+
+// treeSource is a source backed by a git tree object.
+// Only the blobs which are opened are read from the object storage.
+type treeSource struct {
+	// mu serializes the access to the object storage,
+	// which is not safe for concurrent use.
+	mu   sync.Mutex
+	tree *object.Tree
+}
+
+func (s *treeSource) ReadDir(dir string) ([]os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tree
+	if dir != "." {
+		var err error
+		t, err = s.tree.Tree(filepath.ToSlash(dir))
+		if err != nil {
+			if err == object.ErrDirectoryNotFound {
+				return nil, &os.PathError{Op: "readdir", Path: dir, Err: os.ErrNotExist}
+			}
+			return nil, err
+		}
+	}
+	infos := make([]os.FileInfo, len(t.Entries))
+	for i, e := range t.Entries {
+		infos[i] = treeEntryInfo{name: e.Name, mode: e.Mode}
+	}
+	return infos, nil
+}
+
+func (s *treeSource) Open(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.tree.File(filepath.ToSlash(name))
+	if err != nil {
+		if err == object.ErrFileNotFound {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	// read the whole blob here since the reader
+	// accesses the object storage too
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// treeEntryInfo implements os.FileInfo for a git tree entry.
+type treeEntryInfo struct {
+	name string
+	mode filemode.FileMode
+}
+
+func (i treeEntryInfo) Name() string { return i.name }
+func (i treeEntryInfo) Size() int64  { return 0 }
+func (i treeEntryInfo) Mode() os.FileMode {
+	m, err := i.mode.ToOSFileMode()
+	if err != nil {
+		return os.ModeIrregular
+	}
+	return m
+}
+func (i treeEntryInfo) ModTime() time.Time { return time.Time{} }
+func (i treeEntryInfo) IsDir() bool        { return i.mode == filemode.Dir }
+func (i treeEntryInfo) Sys() interface{}   { return nil }
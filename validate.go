@@ -0,0 +1,344 @@
+package repoowners
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem found in a file.
+type Diagnostic struct {
+	// Path is the path of the file relative to the repository root.
+	Path string
+	// Line and Column are the position of the problem, starting at 1.
+	// They are 0 if the position is unknown.
+	Line   int
+	Column int
+	// Message describes the problem.
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.Path, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.Path, d.Line, d.Column, d.Message)
+}
+
+// ValidationError is returned by a strict Loader
+// when some files are invalid.
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		msgs[i] = d.String()
+	}
+	return fmt.Sprintf("%d problems are found:\n%s", len(e.Diagnostics), strings.Join(msgs, "\n"))
+}
+
+// ownersKeys are the keys allowed in OWNERS files. The values tell
+// whether the key is allowed in filters too.
+var ownersKeys = map[string]bool{
+	"approvers":          true,
+	"reviewers":          true,
+	"required_reviewers": true,
+	"labels":             true,
+	"emeritus_approvers": false,
+	"emeritus_reviewers": false,
+	"filters":            false,
+	"no_inherit":         false,
+}
+
+// validator collects the problems of one file.
+type validator struct {
+	path  string
+	diags []Diagnostic
+}
+
+func (v *validator) report(n *yamlv3.Node, format string, args ...interface{}) {
+	d := Diagnostic{
+		Path:    v.path,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if n != nil {
+		d.Line, d.Column = n.Line, n.Column
+	}
+	v.diags = append(v.diags, d)
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// root parses data and returns its root node,
+// or nil if the data is empty or invalid.
+func (v *validator) root(data []byte) *yamlv3.Node {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		d := Diagnostic{Path: v.path, Message: err.Error()}
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+		}
+		v.diags = append(v.diags, d)
+		return nil
+	}
+	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// mapping returns the key and value nodes of a mapping node,
+// reporting duplicate keys and non-string keys.
+func (v *validator) mapping(n *yamlv3.Node, what string) ([]*yamlv3.Node, []*yamlv3.Node) {
+	if n.Kind != yamlv3.MappingNode {
+		v.report(n, "%s must be a mapping", what)
+		return nil, nil
+	}
+	var keys, values []*yamlv3.Node
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, val := n.Content[i], n.Content[i+1]
+		if !isString(k) {
+			v.report(k, "key in %s must be a string", what)
+			continue
+		}
+		if seen[k.Value] {
+			v.report(k, "duplicate key %q in %s", k.Value, what)
+			continue
+		}
+		seen[k.Value] = true
+		keys = append(keys, k)
+		values = append(values, val)
+	}
+	return keys, values
+}
+
+// list validates a list of usernames or labels.
+func (v *validator) list(key, n *yamlv3.Node) {
+	if n.Kind != yamlv3.SequenceNode {
+		v.report(n, "%s must be a list", key.Value)
+		return
+	}
+	if len(n.Content) == 0 {
+		v.report(n, "%s must not be empty", key.Value)
+		return
+	}
+	seen := map[string]bool{}
+	for _, item := range n.Content {
+		if !isString(item) {
+			v.report(item, "%s must be a list of strings", key.Value)
+			continue
+		}
+		name := normalizeName(item.Value)
+		if seen[name] {
+			v.report(item, "duplicate %q in %s", item.Value, key.Value)
+			continue
+		}
+		seen[name] = true
+	}
+}
+
+func isString(n *yamlv3.Node) bool {
+	return n.Kind == yamlv3.ScalarNode && n.ShortTag() == "!!str"
+}
+
+// yaml11Bools are the plain scalars which YAML 1.1 reads as booleans
+// in addition to true and false, e.g. yes and off.
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true,
+	"off": true, "Off": true, "OFF": true,
+}
+
+// isBool returns true if n is a boolean. The validator reads YAML 1.2
+// while the files are parsed as YAML 1.1, so the YAML 1.1 booleans
+// are accepted too.
+func isBool(n *yamlv3.Node) bool {
+	if n.Kind != yamlv3.ScalarNode {
+		return false
+	}
+	return n.ShortTag() == "!!bool" || n.Style == 0 && yaml11Bools[n.Value]
+}
+
+// validateOwners returns the problems of an OWNERS file.
+func validateOwners(path string, data []byte) []Diagnostic {
+	v := &validator{path: path}
+	root := v.root(data)
+	if root == nil {
+		return v.diags
+	}
+	keys, values := v.mapping(root, "OWNERS")
+	for i, k := range keys {
+		val := values[i]
+		if _, ok := ownersKeys[k.Value]; !ok {
+			v.report(k, "unknown key %q", k.Value)
+			continue
+		}
+		switch k.Value {
+		case "no_inherit":
+			if !isBool(val) {
+				v.report(val, "no_inherit must be a boolean")
+			}
+		case "filters":
+			v.filters(val)
+		default:
+			v.list(k, val)
+		}
+	}
+	return v.diags
+}
+
+func (v *validator) filters(n *yamlv3.Node) {
+	patterns, values := v.mapping(n, "filters")
+	if n.Kind == yamlv3.MappingNode && len(n.Content) == 0 {
+		v.report(n, "filters must not be empty")
+	}
+	for i, p := range patterns {
+		if _, err := regexp.Compile(p.Value); err != nil {
+			v.report(p, "invalid filter pattern %q: %v", p.Value, err)
+		}
+		keys, fvalues := v.mapping(values[i], "filter")
+		for j, k := range keys {
+			if !ownersKeys[k.Value] {
+				v.report(k, "unknown key %q in filter", k.Value)
+				continue
+			}
+			v.list(k, fvalues[j])
+		}
+	}
+}
+
+// validateAliases returns the problems of an OWNERS_ALIASES file.
+func validateAliases(path string, data []byte) []Diagnostic {
+	v := &validator{path: path}
+	root := v.root(data)
+	if root == nil {
+		return v.diags
+	}
+	keys, values := v.mapping(root, "OWNERS_ALIASES")
+	for i, k := range keys {
+		if k.Value != "aliases" {
+			v.report(k, "unknown key %q", k.Value)
+			continue
+		}
+		aliases, lists := v.mapping(values[i], "aliases")
+		seen := map[string]bool{}
+		for j, alias := range aliases {
+			name := normalizeName(alias.Value)
+			if seen[name] {
+				v.report(alias, "duplicate alias %q", alias.Value)
+				continue
+			}
+			seen[name] = true
+			v.list(alias, lists[j])
+		}
+	}
+	return v.diags
+}
+
+// UserDirectory tells whether a name is a known user.
+// UsernameSet satisfies this interface.
+type UserDirectory interface {
+	Has(username string) bool
+}
+
+// ValidateIdentities checks that every name listed as approvers, reviewers
+// and required reviewers, and every member of the aliases, is either a
+// defined alias or a user in users. It also reports the aliases which
+// are defined but never used from OWNERS files.
+// The returned diagnostics have no position.
+func (o *Owners) ValidateIdentities(users UserDirectory) []Diagnostic {
+	var diags []Diagnostic
+	used := map[string]bool{}
+	check := func(path, where, name string) {
+		if key := normalizeName(name); o.isAlias(key) {
+			used[key] = true
+			return
+		}
+		if !users.Has(name) {
+			diags = append(diags, Diagnostic{
+				Path:    path,
+				Message: fmt.Sprintf("%q in %s is neither a known user nor a defined alias", name, where),
+			})
+		}
+	}
+
+	dirs := map[string]bool{}
+	for _, role := range roles {
+		mp, fmp, _ := o.roleEntries(role)
+		for dir := range mp {
+			dirs[string(dir)] = true
+		}
+		for dir := range fmp {
+			dirs[string(dir)] = true
+		}
+	}
+	for _, key := range sortedKeys(dirs) {
+		dir := repoPath(key)
+		path := o.ownersFile(dir)
+		for _, role := range roles {
+			mp, fmp, _ := o.roleEntries(role)
+			for _, name := range mp[dir].List() {
+				check(path, string(role), name)
+			}
+			for _, f := range fmp[dir] {
+				for _, name := range f.usernames.List() {
+					check(path, fmt.Sprintf("%s of filter %q", role, f.pattern), name)
+				}
+			}
+		}
+	}
+
+	aliasesFile := o.aliasesFile
+	if aliasesFile == "" {
+		aliasesFile = DefaultAliasesFilename
+	}
+	aliases := make([]string, 0, len(o.aliases))
+	for alias := range o.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	// the aliases used by used aliases are used too
+	var markUsed func(alias string)
+	markUsed = func(alias string) {
+		for _, name := range o.aliases[alias].List() {
+			key := normalizeName(name)
+			if o.isAlias(key) && !used[key] {
+				used[key] = true
+				markUsed(key)
+			}
+		}
+	}
+	for _, alias := range aliases {
+		if used[alias] {
+			markUsed(alias)
+		}
+	}
+	for _, alias := range aliases {
+		for _, name := range o.aliases[alias].List() {
+			if o.isAlias(name) {
+				continue
+			}
+			if !users.Has(name) {
+				diags = append(diags, Diagnostic{
+					Path:    aliasesFile,
+					Message: fmt.Sprintf("%q in alias %q is neither a known user nor a defined alias", name, alias),
+				})
+			}
+		}
+		if !used[alias] {
+			diags = append(diags, Diagnostic{
+				Path:    aliasesFile,
+				Message: fmt.Sprintf("alias %q is defined but never used", alias),
+			})
+		}
+	}
+	return diags
+}
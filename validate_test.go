@@ -0,0 +1,260 @@
+package repoowners
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestValidateOwners(t *testing.T) {
+	tests := []struct {
+		label string
+		in    string
+		want  []Diagnostic
+	}{
+		{
+			label: "valid",
+			in: `approvers:
+- alice
+reviewers:
+- bob
+no_inherit: true
+filters:
+  "\\.go$":
+    labels:
+    - lang/go`,
+			want: nil,
+		},
+		{
+			label: "YAML 1.1 boolean",
+			in: `approvers:
+- alice
+no_inherit: yes`,
+			want: nil,
+		},
+		{
+			label: "quoted boolean",
+			in: `approvers:
+- alice
+no_inherit: "yes"`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 3, Column: 13, Message: `no_inherit must be a boolean`},
+			},
+		},
+		{
+			label: "empty",
+			in:    ``,
+			want:  nil,
+		},
+		{
+			label: "unknown key",
+			in: `approvers:
+- alice
+aprovers:
+- bob`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 3, Column: 1, Message: `unknown key "aprovers"`},
+			},
+		},
+		{
+			label: "duplicate users",
+			in: `approvers:
+- alice
+- bob
+- Alice`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 4, Column: 3, Message: `duplicate "Alice" in approvers`},
+			},
+		},
+		{
+			label: "empty list and non-string entries",
+			in: `approvers: []
+reviewers:
+- 123
+- [bob]
+no_inherit: yes please`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 1, Column: 12, Message: `approvers must not be empty`},
+				{Path: "OWNERS", Line: 3, Column: 3, Message: `reviewers must be a list of strings`},
+				{Path: "OWNERS", Line: 4, Column: 3, Message: `reviewers must be a list of strings`},
+				{Path: "OWNERS", Line: 5, Column: 13, Message: `no_inherit must be a boolean`},
+			},
+		},
+		{
+			label: "duplicate keys",
+			in: `approvers:
+- alice
+approvers:
+- bob`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 3, Column: 1, Message: `duplicate key "approvers" in OWNERS`},
+			},
+		},
+		{
+			label: "invalid filters",
+			in: `filters:
+  "(":
+    approvers:
+    - alice
+  ".*":
+    no_inherit: true`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 2, Column: 3, Message: "invalid filter pattern \"(\": error parsing regexp: missing closing ): `(`"},
+				{Path: "OWNERS", Line: 6, Column: 5, Message: `unknown key "no_inherit" in filter`},
+			},
+		},
+		{
+			label: "not a mapping",
+			in:    `- alice`,
+			want: []Diagnostic{
+				{Path: "OWNERS", Line: 1, Column: 1, Message: `OWNERS must be a mapping`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got := validateOwners("OWNERS", []byte(tt.in))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected diagnostics:\n  got:  %+v\n  want: %+v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	in := `aliases:
+  admins:
+  - alice
+  Admins:
+  - bob
+  members: []
+alias:
+  foo:
+  - bar`
+	want := []Diagnostic{
+		{Path: "OWNERS_ALIASES", Line: 4, Column: 3, Message: `duplicate alias "Admins"`},
+		{Path: "OWNERS_ALIASES", Line: 6, Column: 12, Message: `members must not be empty`},
+		{Path: "OWNERS_ALIASES", Line: 7, Column: 1, Message: `unknown key "alias"`},
+	}
+	got := validateAliases("OWNERS_ALIASES", []byte(in))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diagnostics:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":         "approvers:\n- alice\n",
+		"OWNERS_ALIASES": "aliases:\n  admins: []\n",
+		"foo/OWNERS":     "aprovers:\n- bob\n",
+		"bar/OWNERS":     "approvers:\n- bob\n- bob\n",
+	}
+	writeFiles(t, fs, basePath, files)
+
+	if _, err := NewLoader(WithFs(fs)).LoadLocal(basePath); err != nil {
+		t.Errorf("non-strict loader should not fail: %v", err)
+		return
+	}
+
+	_, err := NewLoader(WithFs(fs), WithStrict()).LoadLocal(basePath)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Diagnostic{
+		{Path: "OWNERS_ALIASES", Line: 2, Column: 11, Message: `admins must not be empty`},
+		{Path: "bar/OWNERS", Line: 3, Column: 3, Message: `duplicate "bob" in approvers`},
+		{Path: "foo/OWNERS", Line: 1, Column: 1, Message: `unknown key "aprovers"`},
+	}
+	if !reflect.DeepEqual(verr.Diagnostics, want) {
+		t.Errorf("unexpected diagnostics:\n  got:  %+v\n  want: %+v", verr.Diagnostics, want)
+		return
+	}
+}
+
+func TestLoadStrictYAML11Boolean(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	writeFiles(t, fs, basePath, map[string]string{
+		"OWNERS":     "approvers:\n- alice\n",
+		"foo/OWNERS": "no_inherit: yes\napprovers:\n- bob\n",
+	})
+	for _, l := range []*Loader{NewLoader(WithFs(fs)), NewLoader(WithFs(fs), WithStrict())} {
+		o, err := l.LoadLocal(basePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !o.options["foo"].NoInheritance {
+			t.Errorf("no_inherit should be set: strict=%t", l.strict)
+			return
+		}
+	}
+}
+
+func TestLoadStrictAliasCycle(t *testing.T) {
+	const basePath = "repo"
+	fs := newMemFS()
+	files := map[string]string{
+		"OWNERS":         "approvers:\n- admins\n",
+		"OWNERS_ALIASES": "aliases:\n  admins:\n  - leads\n  leads:\n  - admins\n",
+		"foo/OWNERS":     "aprovers:\n- bob\n",
+	}
+	writeFiles(t, fs, basePath, files)
+
+	_, err := NewLoader(WithFs(fs), WithStrict()).LoadLocal(basePath)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Diagnostic{
+		{Path: "OWNERS_ALIASES", Message: "alias cycle is detected: admins -> leads -> admins"},
+		{Path: "foo/OWNERS", Line: 1, Column: 1, Message: `unknown key "aprovers"`},
+	}
+	if !reflect.DeepEqual(verr.Diagnostics, want) {
+		t.Errorf("unexpected diagnostics:\n  got:  %+v\n  want: %+v", verr.Diagnostics, want)
+		return
+	}
+}
+
+func TestValidateIdentities(t *testing.T) {
+	owners := Owners{
+		approvers: map[repoPath]UsernameSet{
+			"":    newUsernameSet("alice", "Admins"),
+			"foo": newUsernameSet("bob", "sig-foo"),
+		},
+		reviewers: map[repoPath]UsernameSet{
+			"foo": newUsernameSet("charlie"),
+		},
+		requiredReviewerFilters: map[repoPath][]filteredSet{
+			"bar": {{pattern: regexp.MustCompile(`\.go$`), usernames: newUsernameSet("henry")}},
+		},
+		aliases: map[string]UsernameSet{
+			"admins":  newUsernameSet("alice", "leads"),
+			"leads":   newUsernameSet("ellen"),
+			"members": newUsernameSet("frank", "george"),
+		},
+		ownersFiles: map[repoPath]string{
+			"":    "OWNERS",
+			"foo": "foo/OWNERS.yaml",
+		},
+		aliasesFile: ".github/OWNERS_ALIASES",
+	}
+	users := newUsernameSet("alice", "Bob", "dave", "frank")
+	got := owners.ValidateIdentities(users)
+	want := []Diagnostic{
+		{Path: "bar/OWNERS", Message: `"henry" in required_reviewers of filter "\\.go$" is neither a known user nor a defined alias`},
+		{Path: "foo/OWNERS.yaml", Message: `"sig-foo" in approvers is neither a known user nor a defined alias`},
+		{Path: "foo/OWNERS.yaml", Message: `"charlie" in reviewers is neither a known user nor a defined alias`},
+		{Path: ".github/OWNERS_ALIASES", Message: `"ellen" in alias "leads" is neither a known user nor a defined alias`},
+		{Path: ".github/OWNERS_ALIASES", Message: `"george" in alias "members" is neither a known user nor a defined alias`},
+		{Path: ".github/OWNERS_ALIASES", Message: `alias "members" is defined but never used`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diagnostics:\n  got:  %+v\n  want: %+v", got, want)
+		return
+	}
+}
@@ -0,0 +1,227 @@
+package repoowners
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+)
+
+// gitDir is the name of the git metadata directory of a checkout,
+// which is never walked.
+const gitDir = ".git"
+
+// dirJob is a directory to be walked.
+type dirJob struct {
+	dir string
+	// excludes are the patterns applied to the entries of dir,
+	// including the ones read from the .gitignore files of the parents.
+	excludes []gitignore.Pattern
+}
+
+// ownersResult is the result of reading and parsing
+// the OWNERS file of a directory.
+type ownersResult struct {
+	dir  string
+	path string
+	oc   ownersConfig
+	err  error
+}
+
+// walker is the queue of the directories shared by the workers.
+type walker struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	jobs []dirJob
+	// pending is the number of the directories queued or being walked.
+	pending int
+	results []ownersResult
+	err     error
+}
+
+// next returns the next directory to walk.
+// It returns false when all the directories are walked or the walk failed.
+func (w *walker) next() (dirJob, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.jobs) == 0 && w.pending > 0 && w.err == nil {
+		w.cond.Wait()
+	}
+	if w.pending == 0 || w.err != nil {
+		return dirJob{}, false
+	}
+	job := w.jobs[len(w.jobs)-1]
+	w.jobs = w.jobs[:len(w.jobs)-1]
+	return job, true
+}
+
+// done records the result of walking a directory.
+func (w *walker) done(children []dirJob, res *ownersResult, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	w.jobs = append(w.jobs, children...)
+	w.pending += len(children) - 1
+	if res != nil {
+		w.results = append(w.results, *res)
+	}
+	w.cond.Broadcast()
+}
+
+// walk walks the repository with the workers, which read and parse the
+// OWNERS files as they are found. The results are sorted by directory.
+func (ld *loading) walk(ctx context.Context) ([]ownersResult, error) {
+	w := &walker{
+		jobs:    []dirJob{{dir: ".", excludes: ld.excludePatterns}},
+		pending: 1,
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < ld.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job, ok := w.next(); ok; job, ok = w.next() {
+				w.done(ld.walkDir(ctx, job))
+			}
+		}()
+	}
+	wg.Wait()
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	sort.Slice(w.results, func(i, j int) bool {
+		return w.results[i].dir < w.results[j].dir
+	})
+	sort.Slice(ld.o.walkErrors, func(i, j int) bool {
+		return ld.o.walkErrors[i].Path < ld.o.walkErrors[j].Path
+	})
+	sort.Slice(ld.o.dirs, func(i, j int) bool {
+		return ld.o.dirs[i] < ld.o.dirs[j]
+	})
+	return w.results, nil
+}
+
+// walkDir reads a directory, returning its subdirectories to walk
+// and the parsed OWNERS file if any.
+func (ld *loading) walkDir(ctx context.Context, job dirJob) ([]dirJob, *ownersResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	ld.mu.Lock()
+	ld.o.dirs = append(ld.o.dirs, newRepoPath(job.dir))
+	ld.mu.Unlock()
+	excludes := job.excludes
+	if ld.useGitignore {
+		ps, err := ld.readGitignore(job.dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(ps) > 0 {
+			// the slice may be shared with the siblings
+			excludes = append(excludes[:len(excludes):len(excludes)], ps...)
+		}
+	}
+	infos, err := ld.src.ReadDir(job.dir)
+	if err != nil {
+		return nil, nil, ld.handleWalkError(job.dir, err)
+	}
+
+	var children []dirJob
+	var files, candidates []string
+	for _, info := range infos {
+		path := filepath.Join(job.dir, info.Name())
+		if excluded(excludes, path, info.IsDir()) {
+			continue
+		}
+		if info.IsDir() {
+			if info.Name() != gitDir {
+				children = append(children, dirJob{dir: path, excludes: excludes})
+			}
+			continue
+		}
+		files = append(files, info.Name())
+		if info.Mode().IsRegular() && ld.ownersPriority(info.Name()) >= 0 {
+			candidates = append(candidates, path)
+		}
+	}
+	if len(files) > 0 {
+		ld.mu.Lock()
+		ld.o.files[newRepoPath(job.dir)] = files
+		ld.mu.Unlock()
+	}
+	if len(candidates) == 0 {
+		return children, nil, nil
+	}
+	res := ld.parseOwnersFile(job.dir, candidates)
+	return children, &res, nil
+}
+
+// parseOwnersFile reads and parses the OWNERS file of dir
+// chosen from the candidates.
+func (ld *loading) parseOwnersFile(dir string, candidates []string) ownersResult {
+	// order candidates by the priority of their filenames
+	sort.Slice(candidates, func(i, j int) bool {
+		return ld.ownersPriority(filepath.Base(candidates[i])) < ld.ownersPriority(filepath.Base(candidates[j]))
+	})
+	res := ownersResult{dir: dir, path: candidates[0]}
+	if len(candidates) > 1 {
+		res.path, res.err = ld.selectOwners(dir, candidates)
+		if res.err == nil && !containsString(candidates, res.path) {
+			res.err = fmt.Errorf("selected OWNERS file %q is not one of %q", res.path, candidates)
+		}
+		if res.err != nil {
+			res.path = candidates[0]
+			return res
+		}
+	}
+	data, err := ld.readFile(res.path)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if err := ld.validate(res.path, data, validateOwners); err != nil {
+		res.err = err
+		return res
+	}
+	res.oc, res.err = parseOwners(bytes.NewReader(data))
+	return res
+}
+
+// handleWalkError handles an error in walking path
+// according to the walk error policy.
+// The error in the root is always returned, since nothing is loaded.
+func (ld *loading) handleWalkError(path string, err error) error {
+	werr := &WalkError{Path: path, Err: err}
+	if path == "." {
+		return werr
+	}
+	switch ld.walkErrorPolicy {
+	case WalkErrorFail:
+		return werr
+	case WalkErrorIgnore:
+		return nil
+	}
+	ld.logger.Printf("skipping %s: %v", path, err)
+	ld.mu.Lock()
+	ld.o.walkErrors = append(ld.o.walkErrors, werr)
+	ld.mu.Unlock()
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}